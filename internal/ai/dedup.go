@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/mrf/runbook-generator/internal/history"
+	"github.com/mrf/runbook-generator/internal/processor"
 )
 
 // DedupResult represents commands grouped by semantic similarity.
@@ -51,15 +51,16 @@ Only include groups with more than one command. Commands not in any group will b
 Use 0-based indices matching the input order.`
 
 // DeduplicateCommands uses Claude to identify semantically similar commands.
-func (c *Client) DeduplicateCommands(ctx context.Context, entries []history.Entry) (*DedupResult, error) {
-	if len(entries) == 0 {
+// Only sanitized entries are accepted, so no secret is ever sent to the API.
+func (c *Client) DeduplicateCommands(ctx context.Context, entries processor.Sanitized) (*DedupResult, error) {
+	if entries.Len() == 0 {
 		return &DedupResult{}, nil
 	}
 
 	// Build command list for analysis
 	var sb strings.Builder
 	sb.WriteString("Analyze these commands for semantic duplicates:\n\n")
-	for i, entry := range entries {
+	for i, entry := range entries.Entries() {
 		sb.WriteString(fmt.Sprintf("%d: %s\n", i, entry.Command))
 	}
 
@@ -87,7 +88,7 @@ func (c *Client) DeduplicateCommands(ctx context.Context, entries []history.Entr
 
 // ApplyDedup applies AI deduplication results to entries.
 // Returns the deduplicated entries and a summary of what was removed.
-func ApplyDedup(entries []history.Entry, result *DedupResult) ([]history.Entry, []string) {
+func ApplyDedup(entries processor.Sanitized, result *DedupResult) (processor.Sanitized, []string) {
 	if result == nil || len(result.Groups) == 0 {
 		return entries, nil
 	}
@@ -98,7 +99,7 @@ func ApplyDedup(entries []history.Entry, result *DedupResult) ([]history.Entry,
 
 	for _, group := range result.Groups {
 		for _, idx := range group.Indices {
-			if idx != group.Representative && idx >= 0 && idx < len(entries) {
+			if idx != group.Representative && idx >= 0 && idx < entries.Len() {
 				removeSet[idx] = true
 			}
 		}
@@ -107,13 +108,5 @@ func ApplyDedup(entries []history.Entry, result *DedupResult) ([]history.Entry,
 		}
 	}
 
-	// Filter entries
-	var filtered []history.Entry
-	for i, entry := range entries {
-		if !removeSet[i] {
-			filtered = append(filtered, entry)
-		}
-	}
-
-	return filtered, summaries
+	return entries.Without(removeSet), summaries
 }
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/mrf/runbook-generator/internal/history"
 	"github.com/mrf/runbook-generator/internal/processor"
 )
 
@@ -56,10 +55,12 @@ Be practical and helpful. Focus on what engineers actually need to know.
 Avoid generic filler text - every sentence should add value.`
 
 // GenerateExplanations creates AI-powered explanations for command groups.
-func (c *Client) GenerateExplanations(ctx context.Context, groups []processor.CommandGroup) (*ExplanationResult, error) {
-	if len(groups) == 0 {
+// The groups must be built from sanitized entries.
+func (c *Client) GenerateExplanations(ctx context.Context, sanitized processor.SanitizedGroups) (*ExplanationResult, error) {
+	if sanitized.Len() == 0 {
 		return &ExplanationResult{}, nil
 	}
+	groups := sanitized.Groups()
 
 	// Build description of command groups
 	var sb strings.Builder
@@ -127,17 +128,13 @@ func EnhanceGroups(groups []processor.CommandGroup, explanations *ExplanationRes
 	return enhanced
 }
 
-// ExplainCommands generates explanations for raw command entries.
+// ExplainCommands generates explanations for sanitized command entries.
 // This is useful when you want explanations before grouping.
-func (c *Client) ExplainCommands(ctx context.Context, entries []history.Entry) (*ExplanationResult, error) {
-	if len(entries) == 0 {
+func (c *Client) ExplainCommands(ctx context.Context, entries processor.Sanitized) (*ExplanationResult, error) {
+	if entries.Len() == 0 {
 		return &ExplanationResult{}, nil
 	}
 
-	// Convert entries to a single group for analysis
-	group := processor.CommandGroup{
-		Commands: entries,
-	}
-
-	return c.GenerateExplanations(ctx, []processor.CommandGroup{group})
+	// Treat the entries as a single group for analysis
+	return c.GenerateExplanations(ctx, entries.SingleGroup())
 }
@@ -0,0 +1,214 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+var (
+	ErrNotFound       = errors.New("capture not found")
+	ErrAlreadyRunning = errors.New("capture already running")
+	ErrNoneRunning    = errors.New("no capture is running")
+)
+
+// Capture is a named span of history between `runbook-gen start` and
+// `runbook-gen stop`. From and To are command numbers in the history source
+// the capture was started against.
+type Capture struct {
+	Name        string         `json:"name"`
+	Title       string         `json:"title"`
+	Format      history.Format `json:"format"`
+	HistoryFile string         `json:"history_file"`
+	Session     string         `json:"session,omitempty"`
+	From        int            `json:"from"`
+	To          int            `json:"to,omitempty"`
+	Started     time.Time      `json:"started"`
+	Stopped     time.Time      `json:"stopped,omitempty"`
+}
+
+// Running reports whether the capture has not been stopped yet.
+func (c Capture) Running() bool {
+	return c.Stopped.IsZero()
+}
+
+// Store persists captures in a JSON state file.
+type Store struct {
+	path string
+}
+
+// NewStore creates a store backed by the given state file.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// DefaultPath returns the default state file location.
+func DefaultPath() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "runbook-gen", "captures.json"), nil
+}
+
+// Load returns all saved captures. A missing state file means no captures.
+func (s *Store) Load() ([]Capture, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var captures []Capture
+	if err := json.Unmarshal(data, &captures); err != nil {
+		return nil, fmt.Errorf("corrupt capture state %s: %w", s.path, err)
+	}
+	return captures, nil
+}
+
+// save writes captures atomically with owner-only permissions.
+func (s *Store) save(captures []Capture) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(captures, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Start saves a new running capture. A stopped capture with the same name is
+// replaced; a running one is an error.
+func (s *Store) Start(c Capture) error {
+	captures, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	kept := captures[:0]
+	for _, existing := range captures {
+		if existing.Name == c.Name {
+			if existing.Running() {
+				return fmt.Errorf("%w: %s", ErrAlreadyRunning, c.Name)
+			}
+			continue
+		}
+		kept = append(kept, existing)
+	}
+
+	return s.save(append(kept, c))
+}
+
+// Stop marks a running capture as finished at command number to. An empty
+// name stops the most recently started running capture.
+func (s *Store) Stop(name string, to int, at time.Time) (Capture, error) {
+	captures, err := s.Load()
+	if err != nil {
+		return Capture{}, err
+	}
+
+	idx, err := findRunning(captures, name)
+	if err != nil {
+		return Capture{}, err
+	}
+
+	captures[idx].To = to
+	captures[idx].Stopped = at
+	if err := s.save(captures); err != nil {
+		return Capture{}, err
+	}
+	return captures[idx], nil
+}
+
+// Running returns the capture Stop would stop: the named capture, or the
+// most recently started running one for an empty name.
+func (s *Store) Running(name string) (Capture, error) {
+	captures, err := s.Load()
+	if err != nil {
+		return Capture{}, err
+	}
+
+	idx, err := findRunning(captures, name)
+	if err != nil {
+		return Capture{}, err
+	}
+	return captures[idx], nil
+}
+
+// findRunning returns the index of the running capture called name, or of
+// the most recently started one for an empty name.
+func findRunning(captures []Capture, name string) (int, error) {
+	idx := -1
+	found := false
+	for i, c := range captures {
+		if name != "" && c.Name == name {
+			found = true
+		}
+		if !c.Running() {
+			continue
+		}
+		if name == "" && (idx < 0 || c.Started.After(captures[idx].Started)) {
+			idx = i
+		}
+		if name != "" && c.Name == name {
+			idx = i
+		}
+	}
+
+	switch {
+	case idx >= 0:
+		return idx, nil
+	case name == "":
+		return -1, ErrNoneRunning
+	case found:
+		return -1, fmt.Errorf("capture %q is already stopped", name)
+	default:
+		return -1, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+}
+
+// Get returns the capture with the given name.
+func (s *Store) Get(name string) (Capture, error) {
+	captures, err := s.Load()
+	if err != nil {
+		return Capture{}, err
+	}
+
+	for _, c := range captures {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return Capture{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug derives a capture name from its title, e.g. "Rotate certs" -> "rotate-certs".
+func Slug(title string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if slug == "" {
+		return "capture"
+	}
+	return slug
+}
@@ -0,0 +1,105 @@
+package capture
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestStore_StartStop(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "state", "captures.json"))
+	now := time.Now()
+
+	err := store.Start(Capture{Name: "rotate-certs", Title: "Rotate certs", Format: history.FormatZsh, From: 101, Started: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := store.Start(Capture{Name: "rotate-certs", Started: now}); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("expected ErrAlreadyRunning, got %v", err)
+	}
+
+	stopped, err := store.Stop("", 140, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stopped.Name != "rotate-certs" || stopped.From != 101 || stopped.To != 140 || stopped.Running() {
+		t.Errorf("unexpected stopped capture: %+v", stopped)
+	}
+
+	saved, err := store.Get("rotate-certs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if saved.Title != "Rotate certs" || saved.To != 140 {
+		t.Errorf("expected saved capture to be stopped at 140, got %+v", saved)
+	}
+
+	if _, err := store.Stop("", 150, now); !errors.Is(err, ErrNoneRunning) {
+		t.Errorf("expected ErrNoneRunning, got %v", err)
+	}
+}
+
+func TestStore_StopMostRecent(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "captures.json"))
+	now := time.Now()
+
+	_ = store.Start(Capture{Name: "older", From: 1, Started: now})
+	_ = store.Start(Capture{Name: "newer", From: 5, Started: now.Add(time.Minute)})
+
+	stopped, err := store.Stop("", 9, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stopped.Name != "newer" {
+		t.Errorf("expected most recent capture to stop, got %q", stopped.Name)
+	}
+
+	if _, err := store.Stop("missing", 9, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Rotate certs":            "rotate-certs",
+		"  DB failover (prod)!  ": "db-failover-prod",
+		"???":                     "capture",
+	}
+	for in, want := range tests {
+		if got := Slug(in); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStore_Running(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "captures.json"))
+	now := time.Now()
+
+	if _, err := store.Running(""); !errors.Is(err, ErrNoneRunning) {
+		t.Errorf("expected ErrNoneRunning, got %v", err)
+	}
+
+	_ = store.Start(Capture{Name: "older", From: 1, Started: now})
+	_ = store.Start(Capture{Name: "newer", From: 5, Started: now.Add(time.Minute)})
+
+	if c, err := store.Running(""); err != nil || c.Name != "newer" {
+		t.Errorf("expected the most recent capture, got %q (%v)", c.Name, err)
+	}
+	if c, err := store.Running("older"); err != nil || c.Name != "older" {
+		t.Errorf("expected the named capture, got %q (%v)", c.Name, err)
+	}
+
+	_, _ = store.Stop("older", 4, now)
+	if _, err := store.Running("older"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an already stopped error, got %v", err)
+	}
+	if _, err := store.Running("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
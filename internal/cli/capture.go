@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrf/runbook-generator/internal/capture"
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+var captureNameFlag string
+
+var startCmd = &cobra.Command{
+	Use:   "start [title]",
+	Short: "Start a named capture at the current history position",
+	Long: `Record the current history position and a title, so that a later
+'runbook-gen stop' can turn everything you ran in between into a runbook
+without looking up command numbers.
+
+  runbook-gen start "Rotate certs"
+  ...do the work...
+  runbook-gen stop -o rotate-certs.md
+
+Your shell must write history as you go: setopt INC_APPEND_HISTORY in zsh, or
+PROMPT_COMMAND="history -a" in bash.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStart,
+}
+
+var stopCmd = &cobra.Command{
+	Use:   "stop [name]",
+	Short: "Stop a capture and generate its runbook",
+	Long: `Stop the named capture (or the most recently started one) at the
+current history position and generate a runbook from the commands run since
+'runbook-gen start'. The capture is kept, so it can be regenerated later with
+'runbook-gen --capture NAME'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStop,
+}
+
+func init() {
+	startCmd.Flags().StringVar(&captureNameFlag, "name", "", "capture name (default: derived from the title)")
+	startCmd.Flags().StringVar(&titleFlag, "title", "Runbook", "capture title, used for its runbook")
+	startCmd.Flags().StringVar(&promptFlag, "prompt", "", promptUsage)
+	addSourceFlags(startCmd)
+	addRunbookFlags(stopCmd)
+
+	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(stopCmd)
+}
+
+func runStart(cmd *cobra.Command, args []string) error {
+	extractor, err := newExtractor(nil)
+	if err != nil {
+		return err
+	}
+	if extractor.Path() == "" {
+		return fmt.Errorf("captures need a history file; stdin cannot be captured")
+	}
+
+	position, err := extractor.Count()
+	if err != nil {
+		return err
+	}
+
+	title := titleFlag
+	if len(args) > 0 {
+		title = args[0]
+	}
+	name := captureNameFlag
+	if name == "" {
+		name = capture.Slug(title)
+	}
+
+	store, err := captureStore()
+	if err != nil {
+		return err
+	}
+
+	err = store.Start(capture.Capture{
+		Name:        name,
+		Title:       title,
+		Format:      extractor.Format(),
+		HistoryFile: extractor.Path(),
+		Session:     extractor.Session(),
+		From:        position + 1,
+		Started:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Started capture %q after command #%d\n", name, position)
+	fmt.Fprintf(os.Stderr, "Run 'runbook-gen stop' when you are done\n")
+	return nil
+}
+
+func runStop(cmd *cobra.Command, args []string) error {
+	store, err := captureStore()
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	// Resolve which capture is stopping before counting its history
+	c, err := store.Running(name)
+	if err != nil {
+		return err
+	}
+
+	extractor, err := captureExtractor(c)
+	if err != nil {
+		return err
+	}
+
+	position, err := extractor.Count()
+	if err != nil {
+		return err
+	}
+
+	c, err = store.Stop(c.Name, position, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Stopped capture %q at command #%d\n", c.Name, position)
+	return generateCapture(cmd, c, extractor)
+}
+
+// runCapture generates a runbook for --capture NAME. A capture that is still
+// running is read up to the current history position.
+func runCapture(cmd *cobra.Command) error {
+	store, err := captureStore()
+	if err != nil {
+		return err
+	}
+
+	c, err := store.Get(captureFlag)
+	if err != nil {
+		return err
+	}
+
+	extractor, err := captureExtractor(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Running() {
+		c.To, err = extractor.Count()
+		if err != nil {
+			return err
+		}
+	}
+
+	return generateCapture(cmd, c, extractor)
+}
+
+// generateCapture extracts a capture's commands and generates its runbook,
+// using the saved title unless --title was given.
+func generateCapture(cmd *cobra.Command, c capture.Capture, extractor *history.Extractor) error {
+	if c.To < c.From {
+		return fmt.Errorf("no commands recorded in capture %q (is your shell writing history incrementally?)", c.Name)
+	}
+
+	entries, err := extractor.Extract(c.From, c.To)
+	if err != nil {
+		return fmt.Errorf("failed to extract history: %w", err)
+	}
+
+	entries = dropSelfInvocations(entries)
+	if len(entries) == 0 {
+		return fmt.Errorf("no commands recorded in capture %q", c.Name)
+	}
+
+	fmt.Fprintf(os.Stderr, "Extracted %d commands from capture %q\n", len(entries), c.Name)
+
+	entries, err = filterEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	title := c.Title
+	if cmd.Flags().Changed("title") {
+		title = titleFlag
+	}
+
+	timeRange := fmt.Sprintf("commands #%d to #%d (capture %q, %s to %s)",
+		c.From, c.To, c.Name,
+		c.Started.Format("2006-01-02 15:04:05"),
+		c.Stopped.Format("2006-01-02 15:04:05"))
+	if c.Running() {
+		timeRange = fmt.Sprintf("commands #%d to #%d (capture %q, started %s, still running)",
+			c.From, c.To, c.Name, c.Started.Format("2006-01-02 15:04:05"))
+	}
+
+	return generateRunbook(entries, timeRange, title)
+}
+
+// captureExtractor reopens the history source a capture was started against.
+func captureExtractor(c capture.Capture) (*history.Extractor, error) {
+	extractor, err := history.NewFileExtractor(c.HistoryFile, c.Format)
+	if err != nil {
+		return nil, err
+	}
+	return extractor.WithSession(c.Session), nil
+}
+
+// dropSelfInvocations removes runbook-gen's own start/stop commands, which
+// land inside the captured range when history is written incrementally.
+func dropSelfInvocations(entries []history.Entry) []history.Entry {
+	var result []history.Entry
+	for _, entry := range entries {
+		fields := strings.Fields(entry.Command)
+		if len(fields) > 0 && strings.HasSuffix(fields[0], "runbook-gen") {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// captureStore opens the capture state file.
+func captureStore() (*capture.Store, error) {
+	path, err := capture.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return capture.NewStore(path), nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestDropSelfInvocations(t *testing.T) {
+	entries := []history.Entry{
+		{Number: 1, Command: `runbook-gen start "Rotate certs"`},
+		{Number: 2, Command: "kubectl get secrets"},
+		{Number: 3, Command: "./bin/runbook-gen stop"},
+		{Number: 4, Command: "echo runbook-gen"},
+	}
+
+	result := dropSelfInvocations(entries)
+
+	expected := []string{"kubectl get secrets", "echo runbook-gen"}
+	if len(result) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(result))
+	}
+	for i, exp := range expected {
+		if result[i].Command != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, result[i].Command)
+		}
+	}
+}
+
+func TestRunbookFlags(t *testing.T) {
+	tests := []struct {
+		cmd          *cobra.Command
+		runbook      bool
+		readsHistory bool
+	}{
+		{rootCmd, true, true},
+		{startCmd, false, true},
+		{stopCmd, true, false},
+		{pickCmd, true, true},
+		{hookCmd, false, false},
+		{recordCmd, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cmd.Name(), func(t *testing.T) {
+			if got := tt.cmd.Flags().Lookup("scrub") != nil; got != tt.runbook {
+				t.Errorf("expected --scrub registered %v, got %v", tt.runbook, got)
+			}
+			if got := tt.cmd.Flags().Lookup("history-file") != nil; got != tt.readsHistory {
+				t.Errorf("expected --history-file registered %v, got %v", tt.readsHistory, got)
+			}
+		})
+	}
+}
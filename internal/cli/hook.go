@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+// zshHook records each command from zsh's preexec/precmd hooks. Commands
+// starting with a space are skipped, as HIST_IGNORE_SPACE keeps them out of
+// history.
+const zshHook = `# runbook-gen command recorder
+# Add to ~/.zshrc:  eval "$(runbook-gen hook zsh)"
+zmodload zsh/datetime
+export RUNBOOK_GEN_SESSION="$$.$EPOCHSECONDS"
+
+_runbook_gen_preexec() {
+  [[ $1 == ' '* ]] && return
+  _runbook_gen_cmd=$1
+  _runbook_gen_cwd=$PWD
+  _runbook_gen_start=$EPOCHREALTIME
+}
+
+_runbook_gen_precmd() {
+  local exit=$?
+  [[ -n $_runbook_gen_cmd ]] || return
+  runbook-gen record --exit "$exit" --start "$_runbook_gen_start" \
+    --cwd "$_runbook_gen_cwd" --session "$RUNBOOK_GEN_SESSION" -- "$_runbook_gen_cmd" &!
+  unset _runbook_gen_cmd
+}
+
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec _runbook_gen_preexec
+add-zsh-hook precmd _runbook_gen_precmd
+`
+
+// bashHook emulates preexec with a DEBUG trap and precmd with PROMPT_COMMAND,
+// the way bash-preexec does. The DEBUG trap also fires for every command of
+// PROMPT_COMMAND, so preexec only acts once the prompt is done:
+// _runbook_gen_precmd runs first, while $? is still the command's status, and
+// _runbook_gen_arm runs last, arming preexec for the next command line and
+// noting the last history entry: when HISTCONTROL or HISTIGNORE keeps a
+// command out of history, the entry is unchanged and nothing is recorded. A
+// DEBUG trap the user already has keeps running.
+const bashHook = `# runbook-gen command recorder
+# Add to the end of ~/.bashrc:  eval "$(runbook-gen hook bash)"
+if [[ $PROMPT_COMMAND != *_runbook_gen_precmd* ]]; then
+export RUNBOOK_GEN_SESSION="$$.$(date +%s)"
+
+_runbook_gen_preexec() {
+  [[ -n $COMP_LINE || -z $_runbook_gen_ready ]] && return
+  [[ $BASH_COMMAND == _runbook_gen_precmd* ]] && return
+  unset _runbook_gen_ready
+  local entry
+  entry=$(HISTTIMEFORMAT= builtin history 1)
+  [[ $entry == "$_runbook_gen_last" ]] && return
+  # Strip the "  123* " entry number
+  entry=${entry#"${entry%%[! ]*}"}
+  entry=${entry#"${entry%%[!0-9]*}"}
+  entry=${entry#\*}
+  _runbook_gen_cmd=${entry#"${entry%%[! ]*}"}
+  _runbook_gen_cwd=$PWD
+  _runbook_gen_start=${EPOCHREALTIME:-$(date +%s)}
+}
+
+_runbook_gen_precmd() {
+  local exit=$?
+  unset _runbook_gen_ready
+  if [[ -n $_runbook_gen_cmd ]]; then
+    (runbook-gen record --exit "$exit" --start "$_runbook_gen_start" \
+      --cwd "$_runbook_gen_cwd" --session "$RUNBOOK_GEN_SESSION" -- "$_runbook_gen_cmd" &)
+  fi
+  unset _runbook_gen_cmd
+  return "$exit"
+}
+
+_runbook_gen_arm() {
+  local exit=$?
+  _runbook_gen_last=$(HISTTIMEFORMAT= builtin history 1)
+  _runbook_gen_ready=1
+  return "$exit"
+}
+
+# trap -p prints "trap -- 'command' DEBUG", quoted for the shell
+_runbook_gen_prior_debug=$(trap -p DEBUG)
+_runbook_gen_prior_debug=${_runbook_gen_prior_debug#trap -- }
+eval "_runbook_gen_prior_debug=${_runbook_gen_prior_debug% DEBUG}"
+if [[ -n $_runbook_gen_prior_debug ]]; then
+  trap 'eval "$_runbook_gen_prior_debug"; _runbook_gen_preexec' DEBUG
+else
+  trap '_runbook_gen_preexec' DEBUG
+fi
+
+PROMPT_COMMAND="_runbook_gen_precmd
+${PROMPT_COMMAND:+$PROMPT_COMMAND
+}_runbook_gen_arm"
+fi
+`
+
+var (
+	recordExit    int
+	recordStart   string
+	recordCwd     string
+	recordSession string
+	recordLog     string
+)
+
+var hookCmd = &cobra.Command{
+	Use:       "hook zsh|bash",
+	Short:     "Print shell hook code that records every command",
+	ValidArgs: []string{"zsh", "bash"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	Long: `Print preexec/precmd hook code for your shell. The hooks call
+'runbook-gen record' after each command, building a log that keeps the working
+directory, exit code, duration and git branch that history files leave out.
+
+  eval "$(runbook-gen hook zsh)"    # in ~/.zshrc
+  eval "$(runbook-gen hook bash)"   # in ~/.bashrc
+
+Generate a runbook from the log with --shell hook.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script := zshHook
+		if args[0] == "bash" {
+			script = bashHook
+		}
+		_, err := io.WriteString(cmd.OutOrStdout(), script)
+		return err
+	},
+}
+
+var recordCmd = &cobra.Command{
+	Use:    "record [flags] -- command",
+	Short:  "Append a command to the hook log (called by shell hooks)",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE:   runRecord,
+}
+
+func init() {
+	recordCmd.Flags().IntVar(&recordExit, "exit", 0, "exit status of the command")
+	recordCmd.Flags().StringVar(&recordStart, "start", "", "start time as Unix seconds (fractions allowed)")
+	recordCmd.Flags().StringVar(&recordCwd, "cwd", "", "directory the command ran in (default: current directory)")
+	recordCmd.Flags().StringVar(&recordSession, "session", "", "terminal session identifier")
+	recordCmd.Flags().StringVar(&recordLog, "log", "", "hook log path (default: ~/.local/state/runbook-gen/commands.jsonl)")
+
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(recordCmd)
+}
+
+func runRecord(cmd *cobra.Command, args []string) error {
+	now := time.Now()
+
+	record := history.Record{
+		Time:     now,
+		Command:  strings.Join(args, " "),
+		Dir:      recordCwd,
+		ExitCode: recordExit,
+		Session:  recordSession,
+	}
+
+	if record.Dir == "" {
+		record.Dir, _ = os.Getwd()
+	}
+
+	if recordStart != "" {
+		start, err := parseEpoch(recordStart)
+		if err != nil {
+			return fmt.Errorf("invalid --start: %w", err)
+		}
+		record.Time = start
+		record.DurationMS = now.Sub(start).Milliseconds()
+	}
+
+	record.Host, _ = os.Hostname()
+	record.GitBranch = gitBranch(record.Dir)
+
+	logPath := recordLog
+	if logPath == "" {
+		var err error
+		logPath, err = history.DefaultHookLogPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	return history.AppendRecord(logPath, record)
+}
+
+// parseEpoch parses Unix seconds with an optional fraction, as produced by
+// $EPOCHREALTIME (which uses a comma in some locales).
+func parseEpoch(value string) (time.Time, error) {
+	secs, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(secs*float64(time.Second))), nil
+}
+
+// gitBranch returns the branch checked out in the repository containing dir,
+// reading .git/HEAD directly rather than running git.
+func gitBranch(dir string) string {
+	for dir != "" {
+		gitPath := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitPath); err == nil {
+			gitDir := gitPath
+			if !info.IsDir() {
+				// Worktrees and submodules use a ".git" file pointing elsewhere
+				data, err := os.ReadFile(gitPath)
+				if err != nil {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+
+			head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			ref, ok := bytes.CutPrefix(bytes.TrimSpace(head), []byte("ref: refs/heads/"))
+			if !ok {
+				return "" // detached HEAD
+			}
+			return string(ref)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitBranch(t *testing.T) {
+	repo := t.TempDir()
+	gitDir := filepath.Join(repo, ".git")
+	if err := os.MkdirAll(gitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/feature/certs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	subdir := filepath.Join(repo, "deploy", "k8s")
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := gitBranch(subdir); got != "feature/certs" {
+		t.Errorf("expected feature/certs, got %q", got)
+	}
+
+	// Detached HEAD has no branch
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("3f2a9c1d\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitBranch(subdir); got != "" {
+		t.Errorf("expected no branch for detached HEAD, got %q", got)
+	}
+}
+
+func TestGitBranch_Worktree(t *testing.T) {
+	root := t.TempDir()
+	worktreeGitDir := filepath.Join(root, "main", ".git", "worktrees", "hotfix")
+	if err := os.MkdirAll(worktreeGitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktreeGitDir, "HEAD"), []byte("ref: refs/heads/hotfix\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	worktree := filepath.Join(root, "hotfix")
+	if err := os.MkdirAll(worktree, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+worktreeGitDir+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := gitBranch(worktree); got != "hotfix" {
+		t.Errorf("expected hotfix, got %q", got)
+	}
+}
+
+func TestParseEpoch(t *testing.T) {
+	for _, input := range []string{"1699000000.250000", "1699000000,250000"} {
+		got, err := parseEpoch(input)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", input, err)
+		}
+		if want := time.Unix(1699000000, 250*int64(time.Millisecond)); got.Sub(want).Abs() > time.Millisecond {
+			t.Errorf("parseEpoch(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestBashHook_PromptCommand(t *testing.T) {
+	dir := t.TempDir()
+	rc := `user_status() { :; }
+user_title() { :; }
+PROMPT_COMMAND='user_status; user_title'
+trap 'debug_count=$((debug_count+1))' DEBUG
+`
+	got := runBashHook(t, dir, rc, "true\nfalse\n\necho \"$debug_count\" > debug\n", 3)
+
+	expected := []string{`0|echo "$debug_count" > debug`, "0|true", "1|false"}
+	slices.Sort(expected)
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected records %q, got %q", expected, got)
+	}
+
+	// The user's own DEBUG trap still runs
+	if data, err := os.ReadFile(filepath.Join(dir, "debug")); err != nil || strings.TrimSpace(string(data)) == "" || strings.TrimSpace(string(data)) == "0" {
+		t.Errorf("expected the existing DEBUG trap to keep counting, got %q (%v)", data, err)
+	}
+}
+
+func TestBashHook_HistControl(t *testing.T) {
+	got := runBashHook(t, t.TempDir(), "HISTCONTROL=ignoreboth\n", "echo one\n echo hidden\necho one\nfalse\nexit 0\n", 2)
+
+	expected := []string{"0|echo one", "1|false"}
+	slices.Sort(expected)
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected commands kept out of history not to be recorded, got %q", got)
+	}
+}
+
+// runBashHook runs input through an interactive bash with the hook installed
+// after rc, and returns the "exit|command" records it made, sorted. It waits
+// for at least want records, which are written in the background.
+func runBashHook(t *testing.T, dir, rc, input string, want int) []string {
+	t.Helper()
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+
+	records := filepath.Join(dir, "records")
+	rc += bashHook + `
+runbook-gen() { printf '%s|%s\n' "$3" "${!#}" >> ` + records + `; }
+`
+	rcPath := filepath.Join(dir, "bashrc")
+	if err := os.WriteFile(rcPath, []byte(rc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bash, "--rcfile", rcPath, "-i")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "HOME="+dir, "HISTFILE="+filepath.Join(dir, "history"))
+	cmd.Stdin = strings.NewReader(input)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		data, _ := os.ReadFile(records)
+		got = strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(got) >= want {
+			break
+		}
+	}
+	// Give records that should not exist the time to show up
+	time.Sleep(100 * time.Millisecond)
+	data, _ := os.ReadFile(records)
+	got = strings.Split(strings.TrimSpace(string(data)), "\n")
+	slices.Sort(got)
+	return got
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrf/runbook-generator/internal/config"
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+// runMerged builds one runbook from several history sources, interleaving
+// their commands by timestamp and labelling each with the machine it ran on.
+func runMerged(cmd *cobra.Command, sources []string) error {
+	if cmd.Flags().Changed("from") || cmd.Flags().Changed("to") {
+		return fmt.Errorf("--from/--to cannot span several history files; use --last or --since/--until")
+	}
+
+	byTime := cmd.Flags().Changed("since") || cmd.Flags().Changed("until")
+	if !byTime && !cmd.Flags().Changed("last") {
+		return fmt.Errorf("specify --last or a time window with --since/--until to merge history files")
+	}
+
+	since, until, err := timeWindow()
+	if err != nil {
+		return err
+	}
+
+	var streams [][]history.Entry
+	for _, spec := range sources {
+		label, source := splitLabel(spec)
+
+		extractor, err := openSource(source)
+		if err != nil {
+			return err
+		}
+
+		var entries []history.Entry
+		if byTime {
+			entries, err = extractor.ExtractTimeRange(since, until)
+		} else {
+			entries, err = extractor.ExtractLast(lastFlag)
+		}
+		if errors.Is(err, history.ErrEmptyResult) {
+			fmt.Fprintf(os.Stderr, "No commands from %s\n", source)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract history from %s: %w", source, err)
+		}
+
+		entries = labelEntries(entries, label, source)
+		fmt.Fprintf(os.Stderr, "Extracted %d commands from %s\n", len(entries), entries[0].Source)
+		streams = append(streams, entries)
+	}
+
+	entries := history.Merge(streams...)
+	if len(entries) == 0 {
+		return fmt.Errorf("failed to extract history: %w", history.ErrEmptyResult)
+	}
+	if !byTime && len(entries) > lastFlag {
+		entries = entries[len(entries)-lastFlag:]
+	}
+
+	// The runbook lists the sources itself, after scrubbing
+	timeRange := fmt.Sprintf("%d commands merged from %d histories", len(entries), len(streams))
+	if first, last := entries[0], entries[len(entries)-1]; first.HasTime && last.HasTime {
+		timeRange += fmt.Sprintf(" (%s to %s)",
+			first.Timestamp.Format("2006-01-02 15:04:05"),
+			last.Timestamp.Format("2006-01-02 15:04:05"))
+	}
+
+	entries, err = filterEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	return generateRunbook(entries, timeRange, titleFlag)
+}
+
+// splitLabel separates an optional "LABEL=" prefix from a history source, as
+// in laptop=~/.zsh_history. A prefix containing a path separator is part of
+// the path. The shell does not expand "~" after "=", so a leading "~/" in the
+// path is expanded here.
+func splitLabel(spec string) (label, source string) {
+	if label, source, ok := strings.Cut(spec, "="); ok && label != "" && !strings.ContainsRune(label, filepath.Separator) {
+		return label, config.ExpandHome(source)
+	}
+	return "", config.ExpandHome(spec)
+}
+
+// labelEntries sets the source label on every entry: the explicit label if
+// one was given, otherwise the host the source recorded, otherwise the file
+// name.
+func labelEntries(entries []history.Entry, label, source string) []history.Entry {
+	fallback := filepath.Base(source)
+	if source == "-" {
+		fallback = "stdin"
+	}
+
+	for i := range entries {
+		switch {
+		case label != "":
+			entries[i].Source = label
+		case entries[i].Host != "":
+			entries[i].Source = entries[i].Host
+		default:
+			entries[i].Source = fallback
+		}
+	}
+	return entries
+}
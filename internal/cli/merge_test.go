@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestSplitLabel(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		spec, label, source string
+	}{
+		{"laptop=/home/me/.zsh_history", "laptop", "/home/me/.zsh_history"},
+		{"laptop=~/.zsh_history", "laptop", filepath.Join(home, ".zsh_history")},
+		{"~/bastion_history", "", filepath.Join(home, "bastion_history")},
+		{"bastion_history", "", "bastion_history"},
+		{"./odd=name", "", "./odd=name"},
+		{"=history", "", "=history"},
+	}
+
+	for _, tt := range tests {
+		label, source := splitLabel(tt.spec)
+		if label != tt.label || source != tt.source {
+			t.Errorf("splitLabel(%q) = %q, %q, want %q, %q", tt.spec, label, source, tt.label, tt.source)
+		}
+	}
+}
+
+func TestLabelEntries(t *testing.T) {
+	entries := []history.Entry{
+		{Command: "ls"},
+		{Command: "uptime", Host: "db-1"},
+	}
+
+	labelled := labelEntries(append([]history.Entry{}, entries...), "", "/tmp/bastion_history")
+	if labelled[0].Source != "bastion_history" || labelled[1].Source != "db-1" {
+		t.Errorf("expected file name then host, got %q and %q", labelled[0].Source, labelled[1].Source)
+	}
+
+	labelled = labelEntries(append([]history.Entry{}, entries...), "prod", "-")
+	if labelled[0].Source != "prod" || labelled[1].Source != "prod" {
+		t.Errorf("expected explicit label on every entry, got %q and %q", labelled[0].Source, labelled[1].Source)
+	}
+}
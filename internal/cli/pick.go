@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mrf/runbook-generator/internal/picker"
+)
+
+var pickLimitFlag int
+
+var pickCmd = &cobra.Command{
+	Use:   "pick [history-file | -]",
+	Short: "Choose commands interactively and generate a runbook",
+	Long: `List recent history with command numbers and timestamps, and choose
+what goes into the runbook with the keyboard:
+
+  ↑/↓ j/k        move             PgUp/PgDn g/G   page, jump to ends
+  s / e          mark start/end   space           toggle a command in or out
+  c              clear selection  enter           generate the runbook
+  q / Esc        quit without generating
+
+The selected commands go through the same deduplication, sanitization and
+analysis as any other runbook.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPick,
+}
+
+func init() {
+	pickCmd.Flags().IntVarP(&pickLimitFlag, "limit", "n", 500, "number of recent commands to list")
+	addSourceFlags(pickCmd)
+	addRunbookFlags(pickCmd)
+
+	rootCmd.AddCommand(pickCmd)
+}
+
+func runPick(cmd *cobra.Command, args []string) error {
+	extractor, err := newExtractor(args)
+	if err != nil {
+		return err
+	}
+
+	entries, err := extractor.ExtractLast(pickLimitFlag)
+	if err != nil {
+		return fmt.Errorf("failed to extract history: %w", err)
+	}
+
+	entries, err = filterEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	// Keys come from the terminal itself, so history can still be piped in
+	// and the runbook redirected
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return picker.ErrNoTTY
+	}
+	defer func() { _ = tty.Close() }()
+
+	entries, err = picker.Run(tty, entries)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Picked %d commands from history\n", len(entries))
+
+	first, last := entries[0], entries[len(entries)-1]
+	timeRange := fmt.Sprintf("%d commands picked from #%d to #%d", len(entries), first.Number, last.Number)
+
+	return generateRunbook(entries, timeRange, titleFlag)
+}
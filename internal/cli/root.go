@@ -1,14 +1,17 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/mrf/runbook-generator/internal/ai"
+	"github.com/mrf/runbook-generator/internal/config"
 	"github.com/mrf/runbook-generator/internal/generator"
 	"github.com/mrf/runbook-generator/internal/history"
 	"github.com/mrf/runbook-generator/internal/processor"
@@ -17,24 +20,49 @@ import (
 var (
 	version = "dev"
 
-	fromFlag   int
-	toFlag     int
-	outputFlag string
-	titleFlag  string
+	fromFlag       int
+	toFlag         int
+	outputFlag     string
+	titleFlag      string
+	shellFlag      string
+	sinceFlag      string
+	untilFlag      string
+	lastFlag       int
+	histFlag       []string
+	sessionFlag    string
+	keepFailedFlag bool
+	captureFlag    string
+	includeFlag    []string
+	excludeFlag    []string
+	toolFlag       []string
+	skipToolFlag   []string
+	promptFlag     string
+	outputLogFlag  string
+	outputLines    int
+	entropyLength  int
+	scrubFlag      bool
+	configFlag     string
+	gitleaksFlag   []string
 )
 
 var rootCmd = &cobra.Command{
-	Use:     "runbook-gen",
-	Short:   "Generate runbooks from zsh history",
+	Use:     "runbook-gen [history-file | -]...",
+	Short:   "Generate runbooks from shell history",
 	Version: version,
-	Long: `Runbook Generator analyzes zsh command history between specified command
-numbers, then produces a structured markdown runbook that others can follow
-to reproduce the same workflow.
+	Long: `Runbook Generator analyzes zsh, bash, fish or atuin command history between
+specified command numbers, then produces a structured markdown runbook that
+others can follow to reproduce the same workflow.
 
-Command numbers match what you see when running 'history' in zsh.
+Command numbers match what you see when running 'history' in zsh or bash.
+Fish and atuin history is numbered from 1 starting with the oldest command.
+
+Give several history files (as arguments or repeated --history-file flags) to
+merge them into one chronological runbook. Name a machine with LABEL=PATH,
+e.g. laptop=~/.zsh_history bastion=./bastion_history.
 
 The tool intelligently removes duplicates, infers intent from command
 sequences, and scrubs sensitive data like passwords and API keys.`,
+	Args: cobra.ArbitraryArgs,
 	RunE: run,
 }
 
@@ -48,30 +76,114 @@ func SetVersion(v string) {
 }
 
 func init() {
-	rootCmd.Flags().IntVarP(&fromFlag, "from", "f", 0, "start command number (required)")
-	rootCmd.Flags().IntVarP(&toFlag, "to", "t", 0, "end command number (required)")
-	rootCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "output file path (default: stdout)")
-	rootCmd.Flags().StringVar(&titleFlag, "title", "Runbook", "runbook title")
+	rootCmd.Flags().IntVarP(&fromFlag, "from", "f", 0, "start command number (negative counts back from the latest, e.g. -40)")
+	rootCmd.Flags().IntVarP(&toFlag, "to", "t", -1, "end command number (default: latest command)")
+	rootCmd.Flags().IntVarP(&lastFlag, "last", "n", 0, "select the last N commands")
+	rootCmd.Flags().StringVar(&sinceFlag, "since", "", `select commands run at or after this time (e.g. "2h ago", "14:05", "2006-01-02 15:04")`)
+	rootCmd.Flags().StringVar(&untilFlag, "until", "", `select commands run at or before this time (default: now)`)
+	rootCmd.Flags().StringVar(&captureFlag, "capture", "", "select the commands of a capture recorded with start/stop")
+	addSourceFlags(rootCmd)
+	addRunbookFlags(rootCmd)
 
-	_ = rootCmd.MarkFlagRequired("from")
-	_ = rootCmd.MarkFlagRequired("to")
+	rootCmd.MarkFlagsMutuallyExclusive("from", "since")
+	rootCmd.MarkFlagsMutuallyExclusive("from", "until")
+	rootCmd.MarkFlagsMutuallyExclusive("to", "since")
+	rootCmd.MarkFlagsMutuallyExclusive("to", "until")
+	rootCmd.MarkFlagsMutuallyExclusive("last", "from")
+	rootCmd.MarkFlagsMutuallyExclusive("last", "to")
+	rootCmd.MarkFlagsMutuallyExclusive("last", "since")
+	rootCmd.MarkFlagsMutuallyExclusive("last", "until")
+	rootCmd.MarkFlagsMutuallyExclusive("capture", "from")
+	rootCmd.MarkFlagsMutuallyExclusive("capture", "to")
+	rootCmd.MarkFlagsMutuallyExclusive("capture", "last")
+	rootCmd.MarkFlagsMutuallyExclusive("capture", "since")
+	rootCmd.MarkFlagsMutuallyExclusive("capture", "until")
+}
+
+// promptUsage describes --prompt, which applies to transcripts read as
+// history and to --output-log alike.
+const promptUsage = "regex matching the shell prompt in script/asciinema transcripts, with a group capturing the command"
+
+// addSourceFlags registers the flags choosing which history is read, for
+// the commands that read it: the root command, start and pick.
+func addSourceFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringArrayVar(&histFlag, "history-file", nil, "history file to read, or - for stdin; repeat to merge several (default: $HISTFILE or the shell's usual file)")
+	flags.StringVar(&shellFlag, "shell", "", "history format to read: zsh, bash, fish, atuin, hook, script, asciinema (default: detected)")
+	flags.StringVar(&sessionFlag, "session", "", `atuin or hook log session to read, or "current" for this terminal`)
+}
+
+// addRunbookFlags registers the flags of the commands that generate a
+// runbook (the root command, stop and pick): filtering, redaction and
+// output. Commands that do not generate one, like hook, do not accept them.
+func addRunbookFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVarP(&outputFlag, "output", "o", "", "output file path (default: stdout)")
+	flags.StringVar(&promptFlag, "prompt", "", promptUsage)
+	flags.StringVar(&titleFlag, "title", "Runbook", "runbook title")
+	flags.BoolVar(&keepFailedFlag, "keep-failed", false, "keep commands that exited with a non-zero status")
+	flags.StringArrayVar(&includeFlag, "include", nil, "keep only commands matching this regex (repeatable; a command matching any is kept)")
+	flags.StringArrayVar(&excludeFlag, "exclude", nil, "drop commands matching this regex (repeatable; a command matching any is dropped)")
+	flags.StringSliceVar(&toolFlag, "tool", nil, "keep only commands run with these tools, e.g. kubectl,helm")
+	flags.StringSliceVar(&skipToolFlag, "skip-tool", nil, "drop commands run with these tools, e.g. ls,clear,vim")
+	flags.StringVar(&outputLogFlag, "output-log", "", "transcript or hook log to take command output from, for history files that lack it")
+	flags.IntVar(&outputLines, "expected-output-lines", 15, "lines of captured output to show per step (0 to omit)")
+	flags.IntVar(&entropyLength, "entropy-min-length", processor.DefaultEntropyConfig().MinLength, "redact random-looking tokens at least this long (0 to disable)")
+	flags.BoolVar(&scrubFlag, "scrub", false, "replace email addresses, IPs, internal hosts, AWS account IDs and home directory usernames with pseudonyms")
+	flags.StringVar(&configFlag, "config", "", "config file with extra redaction patterns and allowlisted values (default: ~/.config/runbook-gen/config.yaml or .toml)")
+	flags.StringArrayVar(&gitleaksFlag, "gitleaks-rules", nil, "gitleaks config whose rules are also used for redaction (repeatable)")
 }
 
 func run(cmd *cobra.Command, args []string) error {
-	// Create extractor (uses ~/.zsh_history)
-	extractor, err := history.NewExtractor()
+	if captureFlag != "" {
+		return runCapture(cmd)
+	}
+
+	if sources := historySources(args); len(sources) > 1 {
+		return runMerged(cmd, sources)
+	}
+
+	// Create extractor (uses $HISTFILE, ~/.zsh_history, ~/.bash_history or fish_history)
+	extractor, err := newExtractor(args)
 	if err != nil {
 		return err
 	}
 
 	// Extract history
-	entries, err := extractor.Extract(fromFlag, toFlag)
+	entries, timeRange, err := extractEntries(cmd, extractor)
 	if err != nil {
 		return fmt.Errorf("failed to extract history: %w", err)
 	}
 
 	fmt.Fprintf(os.Stderr, "Extracted %d commands from history\n", len(entries))
 
+	entries, err = filterEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	return generateRunbook(entries, timeRange, titleFlag)
+}
+
+// generateRunbook runs extracted entries through dedup, sanitization, intent
+// analysis and optional AI enhancement, then writes the markdown runbook.
+func generateRunbook(entries []history.Entry, timeRange, title string) error {
+	// Load the config first, so a bad pattern fails before any work is done
+	sanitizer, err := newSanitizer()
+	if err != nil {
+		return err
+	}
+
+	if outputLogFlag != "" {
+		sidecar, err := readOutputLog(outputLogFlag)
+		if err != nil {
+			return err
+		}
+		var attached int
+		entries, attached = history.AttachOutput(entries, sidecar)
+		fmt.Fprintf(os.Stderr, "Attached output to %d commands from %s\n", attached, outputLogFlag)
+	}
+
 	// Check if AI features are available
 	var aiClient *ai.Client
 	if ai.Available() {
@@ -79,38 +191,45 @@ func run(cmd *cobra.Command, args []string) error {
 		fmt.Fprintf(os.Stderr, "AI features enabled (ANTHROPIC_API_KEY detected)\n")
 	}
 
-	// Process: deduplicate
+	// Process: drop failed commands
 	ctx := context.Background()
+	dedup := processor.NewDedup()
+	dedup.DropFailed = !keepFailedFlag
+	if remaining := dedup.DropFailedCommands(entries); len(remaining) != len(entries) {
+		fmt.Fprintf(os.Stderr, "Dropped %d failed commands\n", len(entries)-len(remaining))
+		entries = remaining
+	}
+
+	// Process: sanitize before anything else, the AI client included, sees
+	// the commands
+	sanitized, redactions := sanitizer.Sanitize(entries)
+	if len(redactions) > 0 {
+		fmt.Fprintf(os.Stderr, "Sanitized %d sensitive values\n", len(redactions))
+	}
+
+	// Process: deduplicate
 	if aiClient != nil {
 		fmt.Fprintf(os.Stderr, "Running AI-powered deduplication...\n")
-		dedupResult, err := aiClient.DeduplicateCommands(ctx, entries)
+		dedupResult, err := aiClient.DeduplicateCommands(ctx, sanitized)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "AI deduplication failed, falling back to standard: %v\n", err)
-			dedup := processor.NewDedup()
-			entries = dedup.Process(entries)
+			sanitized = dedup.ProcessSanitized(sanitized)
 		} else {
 			var summaries []string
-			entries, summaries = ai.ApplyDedup(entries, dedupResult)
+			sanitized, summaries = ai.ApplyDedup(sanitized, dedupResult)
 			if len(summaries) > 0 {
 				fmt.Fprintf(os.Stderr, "AI deduplication applied: %d groups merged\n", len(summaries))
 			}
 		}
 	} else {
-		dedup := processor.NewDedup()
-		entries = dedup.Process(entries)
-	}
-	fmt.Fprintf(os.Stderr, "After deduplication: %d commands\n", len(entries))
-
-	// Process: sanitize
-	sanitizer := processor.NewSanitizer()
-	entries, redactions := sanitizer.Process(entries)
-	if len(redactions) > 0 {
-		fmt.Fprintf(os.Stderr, "Sanitized %d sensitive values\n", len(redactions))
+		sanitized = dedup.ProcessSanitized(sanitized)
 	}
+	fmt.Fprintf(os.Stderr, "After deduplication: %d commands\n", sanitized.Len())
 
 	// Process: analyze intent
 	analyzer := processor.NewIntentAnalyzer()
-	groups := analyzer.Analyze(entries)
+	sanitizedGroups := analyzer.AnalyzeSanitized(sanitized)
+	groups := sanitizedGroups.Groups()
 	fmt.Fprintf(os.Stderr, "Organized into %d steps\n", len(groups))
 
 	// Enhance with AI explanations if available
@@ -118,7 +237,7 @@ func run(cmd *cobra.Command, args []string) error {
 	var aiPrerequisites []string
 	if aiClient != nil {
 		fmt.Fprintf(os.Stderr, "Generating AI explanations...\n")
-		explanations, err := aiClient.GenerateExplanations(ctx, groups)
+		explanations, err := aiClient.GenerateExplanations(ctx, sanitizedGroups)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "AI explanation generation failed: %v\n", err)
 		} else {
@@ -130,15 +249,15 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Generate runbook
-	gen := generator.NewMarkdownGenerator()
-	timeRange := fmt.Sprintf("commands #%d to #%d", fromFlag, toFlag)
+	gen := generator.NewMarkdownGenerator().WithOutputLines(outputLines)
 
 	data := generator.RunbookData{
-		Title:           titleFlag,
+		Title:           title,
 		Generated:       time.Now(),
 		TimeRange:       timeRange,
 		Groups:          groups,
 		RedactedCount:   len(redactions),
+		Variables:       sanitizer.Variables(),
 		AIOverview:      aiOverview,
 		AIPrerequisites: aiPrerequisites,
 	}
@@ -157,3 +276,279 @@ func run(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// newSanitizer builds the sanitizer from the --entropy-min-length and --scrub
+// flags, the patterns, allowlist and scrub settings in the config file and any
+// --gitleaks-rules.
+func newSanitizer() (*processor.Sanitizer, error) {
+	cfg, err := config.Load(configFlag)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Path != "" {
+		fmt.Fprintf(os.Stderr, "Loaded %d redaction patterns, %d shell rules and %d allowlisted values from %s\n",
+			len(cfg.Patterns), len(cfg.Rules), len(cfg.Allowlist), cfg.Path)
+	}
+
+	patterns := cfg.Patterns
+	for _, path := range gitleaksFlag {
+		imported, err := config.LoadGitleaks(path)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Loaded %d gitleaks rules from %s\n", len(imported), path)
+		patterns = append(patterns, imported...)
+	}
+
+	entropy := processor.DefaultEntropyConfig()
+	entropy.MinLength = entropyLength
+
+	scrub := cfg.Scrub
+	scrub.Enabled = scrub.Enabled || scrubFlag
+
+	return processor.NewSanitizer().
+		WithPatterns(patterns).
+		WithShellRules(cfg.Rules).
+		WithAllowlist(cfg.Allowlist).
+		WithEntropy(entropy).
+		WithScrubbing(scrub), nil
+}
+
+// filterEntries applies the --tool, --include, --skip-tool and --exclude
+// filters in turn, reporting what each one removed.
+func filterEntries(entries []history.Entry) ([]history.Entry, error) {
+	var filters []*processor.Filter
+	if len(toolFlag) > 0 {
+		filters = append(filters, processor.NewToolFilter(toolFlag, false))
+	}
+	if len(includeFlag) > 0 {
+		f, err := processor.NewPatternFilter(includeFlag, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --include: %w", err)
+		}
+		filters = append(filters, f)
+	}
+	if len(skipToolFlag) > 0 {
+		filters = append(filters, processor.NewToolFilter(skipToolFlag, true))
+	}
+	if len(excludeFlag) > 0 {
+		f, err := processor.NewPatternFilter(excludeFlag, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --exclude: %w", err)
+		}
+		filters = append(filters, f)
+	}
+
+	for _, f := range filters {
+		before := len(entries)
+		entries = f.Apply(entries)
+		fmt.Fprintf(os.Stderr, "After %s: %d commands (%d removed)\n", f.Name, len(entries), before-len(entries))
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no commands left after filtering")
+	}
+	return entries, nil
+}
+
+// historySources lists the history inputs given with --history-file and as
+// positional arguments.
+func historySources(args []string) []string {
+	return append(append([]string{}, histFlag...), args...)
+}
+
+// readOutputLog reads every command from a sidecar output source. Its format
+// is sniffed independently of --shell, which describes the main history.
+func readOutputLog(path string) ([]history.Entry, error) {
+	head, err := readHead(path, 4096)
+	if err != nil {
+		return nil, err
+	}
+
+	extractor, err := history.NewFileExtractor(path, history.SniffFormat(head))
+	if err != nil {
+		return nil, err
+	}
+	if promptFlag != "" {
+		if _, err := extractor.WithPrompt(promptFlag); err != nil {
+			return nil, err
+		}
+	}
+
+	total, err := extractor.Count()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output log: %w", err)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	return extractor.Extract(1, total)
+}
+
+// newExtractor opens the single history source named by --history-file or
+// the positional argument ("-" for stdin), falling back to the current user's
+// history.
+func newExtractor(args []string) (*history.Extractor, error) {
+	sources := historySources(args)
+	switch len(sources) {
+	case 0:
+		return openSource("")
+	case 1:
+		_, source := splitLabel(sources[0])
+		return openSource(source)
+	default:
+		return nil, fmt.Errorf("this command reads a single history file, got %d", len(sources))
+	}
+}
+
+// openSource opens a history file, "-" for stdin, or "" for the current
+// user's history. Without --shell, the format of an explicit file is sniffed
+// from its contents.
+func openSource(source string) (*history.Extractor, error) {
+	var format history.Format
+	if shellFlag != "" {
+		f, err := history.ParseFormat(shellFlag)
+		if err != nil {
+			return nil, err
+		}
+		format = f
+	}
+
+	var extractor *history.Extractor
+	var err error
+
+	switch source {
+	case "":
+		if format == "" {
+			format = history.DetectFormat()
+		}
+		extractor, err = history.NewExtractorForFormat(format)
+
+	case "-":
+		data, readErr := io.ReadAll(os.Stdin)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read history from stdin: %w", readErr)
+		}
+		if format == "" {
+			format = history.SniffFormat(data)
+		}
+		extractor, err = history.NewReaderExtractor(bytes.NewReader(data), format)
+
+	default:
+		if format == "" {
+			head, headErr := readHead(source, 4096)
+			if headErr != nil {
+				return nil, headErr
+			}
+			format = history.SniffFormat(head)
+		}
+		extractor, err = history.NewFileExtractor(source, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if promptFlag != "" {
+		if format != history.FormatScript && format != history.FormatAsciinema {
+			return nil, fmt.Errorf("--prompt only applies to transcripts (--shell script|asciinema)")
+		}
+		if _, err := extractor.WithPrompt(promptFlag); err != nil {
+			return nil, err
+		}
+	}
+
+	if sessionFlag != "" {
+		var sessionEnv string
+		switch format {
+		case history.FormatAtuin:
+			sessionEnv = "ATUIN_SESSION"
+		case history.FormatHook:
+			sessionEnv = "RUNBOOK_GEN_SESSION"
+		default:
+			return nil, fmt.Errorf("--session requires atuin or hook log history (--shell atuin|hook)")
+		}
+		session := sessionFlag
+		if session == "current" {
+			session = os.Getenv(sessionEnv)
+			if session == "" {
+				return nil, fmt.Errorf("--session current: %s is not set", sessionEnv)
+			}
+		}
+		extractor.WithSession(session)
+	}
+
+	return extractor, nil
+}
+
+// readHead returns up to n bytes from the start of a file.
+func readHead(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w at %s", history.ErrHistoryNotFound, path)
+		}
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return io.ReadAll(io.LimitReader(file, n))
+}
+
+// extractEntries selects history by command number (--from/--to, --last) or
+// by time window (--since/--until), and describes the selection for the
+// runbook notes.
+func extractEntries(cmd *cobra.Command, extractor *history.Extractor) ([]history.Entry, string, error) {
+	if !cmd.Flags().Changed("since") && !cmd.Flags().Changed("until") {
+		var entries []history.Entry
+		var err error
+		switch {
+		case cmd.Flags().Changed("last"):
+			entries, err = extractor.ExtractLast(lastFlag)
+		case cmd.Flags().Changed("from"):
+			entries, err = extractor.Extract(fromFlag, toFlag)
+		default:
+			return nil, "", fmt.Errorf("specify a range with --from/--to, --last or a time window with --since/--until")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		first, last := entries[0], entries[len(entries)-1]
+		return entries, fmt.Sprintf("commands #%d to #%d", first.Number, last.Number), nil
+	}
+
+	since, until, err := timeWindow()
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries, err := extractor.ExtractTimeRange(since, until)
+	if err != nil {
+		return nil, "", err
+	}
+
+	first, last := entries[0], entries[len(entries)-1]
+	timeRange := fmt.Sprintf("commands #%d to #%d (%s to %s)",
+		first.Number, last.Number,
+		first.Timestamp.Format("2006-01-02 15:04:05"),
+		last.Timestamp.Format("2006-01-02 15:04:05"))
+	return entries, timeRange, nil
+}
+
+// timeWindow parses --since and --until. An unset flag leaves that end of the
+// window open.
+func timeWindow() (since, until time.Time, err error) {
+	now := time.Now()
+	if sinceFlag != "" {
+		since, err = parseTime(sinceFlag, now)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if untilFlag != "" {
+		until, err = parseTime(untilFlag, now)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	return since, until, nil
+}
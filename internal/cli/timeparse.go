@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// relativePattern matches relative times such as "2h ago", "90m ago" or "1d ago".
+var relativePattern = regexp.MustCompile(`^(\d+)\s*([smhdw])\s+ago$`)
+
+// absoluteLayouts are the accepted formats for dates with a day component.
+var absoluteLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// clockLayouts are the accepted formats for a time of day.
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+// parseTime converts a --since/--until value into a point in time.
+//
+// Accepted forms:
+//
+//	2h ago, 30m ago, 1d ago, 2w ago   relative to now
+//	2024-03-01 14:05, RFC3339, ...    absolute, in local time
+//	14:05, today 14:05                time of day today
+//	yesterday 14:05                   time of day yesterday
+func parseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	// Keywords are case-insensitive; layouts such as RFC3339 are not
+	lower := strings.ToLower(value)
+	if lower == "now" {
+		return now, nil
+	}
+
+	if m := relativePattern.FindStringSubmatch(lower); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit := map[string]time.Duration{
+			"s": time.Second,
+			"m": time.Minute,
+			"h": time.Hour,
+			"d": 24 * time.Hour,
+			"w": 7 * 24 * time.Hour,
+		}[m[2]]
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	for _, layout := range absoluteLayouts {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	day := now
+	clock := lower
+	switch {
+	case strings.HasPrefix(lower, "yesterday"):
+		day = now.AddDate(0, 0, -1)
+		clock = strings.TrimSpace(strings.TrimPrefix(lower, "yesterday"))
+	case strings.HasPrefix(lower, "today"):
+		clock = strings.TrimSpace(strings.TrimPrefix(lower, "today"))
+	}
+
+	if clock == "" {
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location()), nil
+	}
+
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q (try \"2h ago\", \"14:05\", \"yesterday 14:05\" or \"2006-01-02 15:04\")", value)
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 15, 16, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"now", now},
+		{"2h ago", now.Add(-2 * time.Hour)},
+		{"90m ago", now.Add(-90 * time.Minute)},
+		{"1d ago", now.Add(-24 * time.Hour)},
+		{"14:05", time.Date(2024, 3, 15, 14, 5, 0, 0, time.Local)},
+		{"today 14:05:30", time.Date(2024, 3, 15, 14, 5, 30, 0, time.Local)},
+		{"yesterday 14:05", time.Date(2024, 3, 14, 14, 5, 0, 0, time.Local)},
+		{"yesterday", time.Date(2024, 3, 14, 0, 0, 0, 0, time.Local)},
+		{"2024-03-01 09:15", time.Date(2024, 3, 1, 9, 15, 0, 0, time.Local)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{"2024-05-01T10:00:00", time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)},
+		{"2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"Yesterday 14:05", time.Date(2024, 3, 14, 14, 5, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTime(tt.input, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("got %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSelectionFlagsExclusive(t *testing.T) {
+	tests := []struct {
+		args  []string
+		valid bool
+	}{
+		{[]string{"--from", "5", "--to", "10"}, true},
+		{[]string{"--since", "2h ago", "--until", "14:00"}, true},
+		{[]string{"--to", "10", "--since", "2h ago"}, false},
+		{[]string{"--to", "10", "--until", "14:00"}, false},
+		{[]string{"--to", "10", "--capture", "deploy"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			t.Cleanup(resetFlags)
+			if err := rootCmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := rootCmd.ValidateFlagGroups(); (err == nil) != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+// resetFlags restores the root command's selection flags after a test parsed
+// some.
+func resetFlags() {
+	for _, name := range []string{"from", "to", "last", "since", "until", "capture"} {
+		if f := rootCmd.Flags().Lookup(name); f.Changed {
+			_ = f.Value.Set(f.DefValue)
+			f.Changed = false
+		}
+	}
+}
+
+func TestParseTime_Invalid(t *testing.T) {
+	if _, err := parseTime("last tuesday", time.Now()); err == nil {
+		t.Error("expected error for unrecognized time")
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `patterns:
+  - name: acme-live-token
+    regex: 'acme_live_[A-Za-z0-9]{8}'
+  - name: acme-vault-login
+    regex: 'acme-vault login'
+    full_remove: true
+allowlist:
+  - acme_live_EXAMPLE0
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `allowlist = ["acme_live_EXAMPLE0"]
+
+[[patterns]]
+name = "acme-live-token"
+regex = 'acme_live_[A-Za-z0-9]{8}'
+
+[[patterns]]
+name = "acme-vault-login"
+regex = 'acme-vault login'
+full_remove = true
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file, tt.content)
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if cfg.Path != path {
+				t.Errorf("expected path %q, got %q", path, cfg.Path)
+			}
+			if len(cfg.Patterns) != 2 {
+				t.Fatalf("expected 2 patterns, got %d", len(cfg.Patterns))
+			}
+
+			token := cfg.Patterns[0]
+			if token.Name != "acme-live-token" || token.Replacement != "<REDACTED>" || token.FullRemove {
+				t.Errorf("unexpected pattern %+v", token)
+			}
+			if !token.Regex.MatchString("login acme_live_Ab3dE6gH") {
+				t.Errorf("expected regex to match an acme token")
+			}
+			if !cfg.Patterns[1].FullRemove {
+				t.Errorf("expected full_remove to be read")
+			}
+
+			if len(cfg.Allowlist) != 1 || cfg.Allowlist[0] != "acme_live_EXAMPLE0" {
+				t.Errorf("expected allowlist [acme_live_EXAMPLE0], got %q", cfg.Allowlist)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "invalid regex",
+			content:  "patterns:\n  - name: broken\n    regex: 'acme_(live'\n",
+			expected: `pattern "broken": invalid regex`,
+		},
+		{
+			name:     "missing regex",
+			content:  "patterns:\n  - name: empty\n",
+			expected: `pattern "empty": missing regex`,
+		},
+		{
+			name:     "missing name",
+			content:  "patterns:\n  - regex: 'acme'\n",
+			expected: "pattern 1: missing name",
+		},
+		{
+			name:     "shell rule without a target",
+			content:  "shell_rules:\n  - name: empty\n    tools: [acme]\n",
+			expected: `shell rule "empty": needs flags, env or args`,
+		},
+		{
+			name:     "invalid shell rule env",
+			content:  "shell_rules:\n  - name: env\n    env: '(ACME'\n",
+			expected: `shell rule "env": invalid env regex`,
+		},
+		{
+			name:     "invalid internal domain",
+			content:  "scrub:\n  internal_domains: ['corp acme']\n",
+			expected: `scrub: invalid internal domain "corp acme"`,
+		},
+		{
+			name:     "unknown key",
+			content:  "patterns:\n  - name: typo\n    regex: 'acme'\n    fullremove: true\n",
+			expected: "fullremove",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, "config.yaml", tt.content)
+
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.expected) || !strings.Contains(err.Error(), path) {
+				t.Errorf("expected error naming %q and the file, got %q", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoad_ShellRules(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `shell_rules:
+  - name: acme-pin
+    tools: [acme]
+    flags: [--pin, -P]
+    attached_only: true
+  - name: acme-env
+    env: '^ACME_'
+    env_flags: [--var]
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Rules) != 2 {
+		t.Fatalf("expected 2 shell rules, got %d", len(cfg.Rules))
+	}
+
+	pin := cfg.Rules[0]
+	if pin.Name != "acme-pin" || len(pin.Tools) != 1 || len(pin.Flags) != 2 || !pin.AttachedOnly {
+		t.Errorf("unexpected rule %+v", pin)
+	}
+	env := cfg.Rules[1]
+	if env.Env == nil || !env.Env.MatchString("ACME_KEY") || len(env.EnvFlags) != 1 {
+		t.Errorf("unexpected rule %+v", env)
+	}
+}
+
+func TestLoad_Scrub(t *testing.T) {
+	path := writeConfig(t, "config.toml", `[scrub]
+enabled = true
+internal_domains = ["corp.acme.com", ".acme.internal"]
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Scrub.Enabled || len(cfg.Scrub.InternalDomains) != 2 {
+		t.Errorf("unexpected scrub config %+v", cfg.Scrub)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("expected no error without a config file, got %v", err)
+	}
+	if cfg.Path != "" || len(cfg.Patterns) != 0 {
+		t.Errorf("expected an empty config, got %+v", cfg)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a named file that does not exist")
+	}
+}
+
+func TestLoad_DefaultDir(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	dir := filepath.Join(configHome, "runbook-gen")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("allowlist = [\"keep-me\"]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Path != path {
+		t.Errorf("expected %q, got %q", path, cfg.Path)
+	}
+	if len(cfg.Allowlist) != 1 {
+		t.Errorf("expected 1 allowlisted value, got %d", len(cfg.Allowlist))
+	}
+}
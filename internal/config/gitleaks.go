@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/mrf/runbook-generator/internal/processor"
+)
+
+// gitleaksConfig is the part of a gitleaks config file that applies to
+// commands. Paths, commits, tags and [extend] only make sense when scanning
+// a repository and are ignored.
+type gitleaksConfig struct {
+	Rules      []gitleaksRule      `toml:"rules"`
+	Allowlist  *gitleaksAllowlist  `toml:"allowlist"` // before gitleaks 8.25
+	Allowlists []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksRule struct {
+	ID          string              `toml:"id"`
+	Regex       string              `toml:"regex"`
+	SecretGroup int                 `toml:"secretGroup"`
+	Entropy     float64             `toml:"entropy"`
+	Keywords    []string            `toml:"keywords"`
+	Path        string              `toml:"path"`
+	Allowlist   *gitleaksAllowlist  `toml:"allowlist"` // before gitleaks 8.25
+	Allowlists  []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksAllowlist struct {
+	Condition   string   `toml:"condition"`
+	RegexTarget string   `toml:"regexTarget"`
+	Regexes     []string `toml:"regexes"`
+	Stopwords   []string `toml:"stopwords"`
+	Paths       []string `toml:"paths"`
+	Commits     []string `toml:"commits"`
+	TargetRules []string `toml:"targetRules"`
+}
+
+// LoadGitleaks reads a gitleaks config file and turns its rules into
+// sanitizer patterns, so runbook redaction can share the rules used to scan
+// commits.
+func LoadGitleaks(path string) ([]processor.Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading gitleaks rules: %w", err)
+	}
+
+	patterns, err := ParseGitleaks(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// ParseGitleaks turns gitleaks rules into patterns. The rule id becomes the
+// pattern name, keywords become the prefilter that decides whether the regex
+// runs at all, and only the secretGroup is redacted. Rules limited to file
+// paths, and rules without a regex, do not apply to commands and are
+// skipped.
+func ParseGitleaks(data []byte) ([]processor.Pattern, error) {
+	var cfg gitleaksConfig
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	global := cfg.Allowlists
+	if cfg.Allowlist != nil {
+		global = append(global, *cfg.Allowlist)
+	}
+
+	var patterns []processor.Pattern
+	for i, rule := range cfg.Rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("rule %d: missing id", i+1)
+		}
+		if rule.Regex == "" || rule.Path != "" {
+			continue
+		}
+
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: invalid regex: %w", rule.ID, err)
+		}
+
+		// Like gitleaks, fall back to the first group that matched when no
+		// secretGroup is given
+		secretGroup := rule.SecretGroup
+		if secretGroup > re.NumSubexp() {
+			return nil, fmt.Errorf("rule %q: secretGroup %d, but the regex has %d groups", rule.ID, secretGroup, re.NumSubexp())
+		}
+		if secretGroup == 0 && re.NumSubexp() > 0 {
+			secretGroup = 1
+		}
+
+		pattern := processor.Pattern{
+			Name:        rule.ID,
+			Regex:       re,
+			Replacement: "<REDACTED>",
+			Keywords:    rule.Keywords,
+			SecretGroup: secretGroup,
+			MinEntropy:  rule.Entropy,
+		}
+
+		allowlists := rule.Allowlists
+		if rule.Allowlist != nil {
+			allowlists = append(allowlists, *rule.Allowlist)
+		}
+		for _, allowlist := range global {
+			if len(allowlist.TargetRules) == 0 || slices.Contains(allowlist.TargetRules, rule.ID) {
+				allowlists = append(allowlists, allowlist)
+			}
+		}
+
+		for _, allowlist := range allowlists {
+			compiled, ok, err := compileAllowlist(allowlist)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", rule.ID, err)
+			}
+			if ok {
+				pattern.Allowlists = append(pattern.Allowlists, compiled)
+			}
+		}
+
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// compileAllowlist converts a gitleaks allowlist. It reports false for
+// allowlists that can never match a command: those with only paths or
+// commits, or that require a path or commit to match as well.
+func compileAllowlist(a gitleaksAllowlist) (processor.PatternAllowlist, bool, error) {
+	requireAll := strings.EqualFold(a.Condition, "AND")
+	if len(a.Regexes) == 0 && len(a.Stopwords) == 0 {
+		return processor.PatternAllowlist{}, false, nil
+	}
+	if requireAll && (len(a.Paths) > 0 || len(a.Commits) > 0) {
+		return processor.PatternAllowlist{}, false, nil
+	}
+
+	switch a.RegexTarget {
+	case "", "secret", "match", "line":
+	default:
+		return processor.PatternAllowlist{}, false, fmt.Errorf("unknown allowlist regexTarget %q", a.RegexTarget)
+	}
+
+	compiled := processor.PatternAllowlist{
+		RegexTarget: a.RegexTarget,
+		Stopwords:   a.Stopwords,
+		RequireAll:  requireAll,
+	}
+	for _, expr := range a.Regexes {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return processor.PatternAllowlist{}, false, fmt.Errorf("invalid allowlist regex: %w", err)
+		}
+		compiled.Regexes = append(compiled.Regexes, re)
+	}
+	return compiled, true, nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/processor"
+)
+
+const gitleaksRules = `
+title = "acme gitleaks config"
+
+[extend]
+useDefault = true
+
+[[rules]]
+id = "acme-api-key"
+description = "ACME API key"
+regex = '''(?i)acme[_-]?key\s*[=:]\s*['"]?([a-z0-9]{24})['"]?'''
+keywords = ["acme"]
+
+[[rules]]
+id = "acme-session"
+regex = '''(session)=(ses_[A-Za-z0-9]{16})'''
+secretGroup = 2
+keywords = ["ses_"]
+  [[rules.allowlists]]
+  stopwords = ["example"]
+
+[[rules]]
+id = "acme-deploy-token"
+regex = '''adt_[A-Za-z0-9]{20}'''
+entropy = 3.5
+keywords = ["adt_"]
+  [rules.allowlist]
+  regexTarget = "line"
+  regexes = ['''^echo ''']
+
+[[rules]]
+id = "acme-pem"
+path = '''\.pem$'''
+regex = '''BEGIN ACME'''
+
+[[rules]]
+id = "acme-files"
+path = '''secrets/'''
+
+[allowlist]
+description = "global"
+regexes = ['''^ses_TESTTESTTESTTEST$''']
+paths = ['''testdata/''']
+`
+
+func TestParseGitleaks(t *testing.T) {
+	patterns, err := ParseGitleaks([]byte(gitleaksRules))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, p := range patterns {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "acme-api-key,acme-session,acme-deploy-token" {
+		t.Fatalf("expected the three command rules, got %q", got)
+	}
+
+	if patterns[0].SecretGroup != 1 {
+		t.Errorf("expected secretGroup to default to 1, got %d", patterns[0].SecretGroup)
+	}
+	if patterns[1].SecretGroup != 2 {
+		t.Errorf("expected secretGroup 2, got %d", patterns[1].SecretGroup)
+	}
+	if patterns[2].MinEntropy != 3.5 {
+		t.Errorf("expected entropy 3.5, got %v", patterns[2].MinEntropy)
+	}
+	if len(patterns[1].Allowlists) != 2 {
+		t.Errorf("expected rule and global allowlists, got %d", len(patterns[1].Allowlists))
+	}
+
+	sanitizer := processor.NewSanitizer().WithEntropy(processor.EntropyConfig{}).WithPatterns(patterns)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "only the secret group is redacted",
+			input:    "curl -d acme_key=abcdef0123456789abcdef01 https://acme.dev",
+			expected: `curl -d acme_key="${ACME_API_KEY}" https://acme.dev`,
+		},
+		{
+			name:     "explicit secret group",
+			input:    "curl -b session=ses_Ab12Cd34Ef56Gh78 https://acme.dev",
+			expected: `curl -b session="${ACME_SESSION}" https://acme.dev`,
+		},
+		{
+			name:     "stopword",
+			input:    "curl -b session=ses_ExampleAb12Cd34 https://acme.dev",
+			expected: "curl -b session=ses_ExampleAb12Cd34 https://acme.dev",
+		},
+		{
+			name:     "global allowlist",
+			input:    "curl -b session=ses_TESTTESTTESTTEST https://acme.dev",
+			expected: "curl -b session=ses_TESTTESTTESTTEST https://acme.dev",
+		},
+		{
+			name:     "whole match without groups",
+			input:    "deploy --with adt_Zx81Qw72Er63Ty54Ui45",
+			expected: `deploy --with "${ACME_DEPLOY_TOKEN}"`,
+		},
+		{
+			name:     "below rule entropy",
+			input:    "deploy --with adt_aaaaaaaaaaaaaaaaaaaa",
+			expected: "deploy --with adt_aaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			name:     "line allowlist",
+			input:    "echo adt_Zx81Qw72Er63Ty54Ui45",
+			expected: "echo adt_Zx81Qw72Er63Ty54Ui45",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := sanitizer.SanitizeString(tt.input); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseGitleaks_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    string
+		expected string
+	}{
+		{
+			name:     "invalid regex",
+			rules:    "[[rules]]\nid = \"broken\"\nregex = '''acme_(live'''\n",
+			expected: `rule "broken": invalid regex`,
+		},
+		{
+			name:     "missing id",
+			rules:    "[[rules]]\nregex = '''acme'''\n",
+			expected: "rule 1: missing id",
+		},
+		{
+			name:     "secret group out of range",
+			rules:    "[[rules]]\nid = \"group\"\nregex = '''acme_(\\w+)'''\nsecretGroup = 2\n",
+			expected: `rule "group": secretGroup 2`,
+		},
+		{
+			name:     "invalid allowlist regex",
+			rules:    "[[rules]]\nid = \"allow\"\nregex = '''acme'''\n[rules.allowlist]\nregexes = ['''(''']\n",
+			expected: `rule "allow": invalid allowlist regex`,
+		},
+		{
+			name:     "not TOML",
+			rules:    "[[rules]\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGitleaks([]byte(tt.rules))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %q", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Gitleaks(t *testing.T) {
+	rules := writeConfig(t, "gitleaks.toml", gitleaksRules)
+	path := writeConfig(t, "config.yaml", "gitleaks:\n  - "+rules+"\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Patterns) != 3 {
+		t.Errorf("expected 3 imported patterns, got %d", len(cfg.Patterns))
+	}
+
+	missing := writeConfig(t, "config.yaml", "gitleaks:\n  - missing.toml\n")
+	if _, err := Load(missing); err == nil {
+		t.Error("expected an error for a missing rules file")
+	}
+}
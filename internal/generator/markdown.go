@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mrf/runbook-generator/internal/history"
 	"github.com/mrf/runbook-generator/internal/processor"
 )
 
@@ -15,14 +16,17 @@ type RunbookData struct {
 	TimeRange       string
 	Groups          []processor.CommandGroup
 	RedactedCount   int
-	AIOverview      string   // AI-generated overview (optional)
-	AIPrerequisites []string // AI-generated prerequisites (optional)
+	Variables       []processor.Variable // Variables that redacted secrets were replaced with
+	AIOverview      string               // AI-generated overview (optional)
+	AIPrerequisites []string             // AI-generated prerequisites (optional)
 }
 
 // MarkdownGenerator generates markdown runbooks from command groups.
 type MarkdownGenerator struct {
 	includeTimestamps bool
 	includeDirs       bool
+	longRunning       time.Duration
+	outputLines       int
 }
 
 // NewMarkdownGenerator creates a new markdown generator.
@@ -30,6 +34,8 @@ func NewMarkdownGenerator() *MarkdownGenerator {
 	return &MarkdownGenerator{
 		includeTimestamps: false,
 		includeDirs:       true,
+		longRunning:       time.Minute,
+		outputLines:       15,
 	}
 }
 
@@ -45,6 +51,20 @@ func (g *MarkdownGenerator) WithDirectories(include bool) *MarkdownGenerator {
 	return g
 }
 
+// WithLongRunningThreshold sets how long a command must have run before it is
+// marked as long-running. Zero disables the markers.
+func (g *MarkdownGenerator) WithLongRunningThreshold(d time.Duration) *MarkdownGenerator {
+	g.longRunning = d
+	return g
+}
+
+// WithOutputLines sets how many lines of a step's captured output are shown
+// in its "Expected output" block. Zero omits the blocks.
+func (g *MarkdownGenerator) WithOutputLines(n int) *MarkdownGenerator {
+	g.outputLines = n
+	return g
+}
+
 // Generate creates a markdown runbook from the provided data.
 func (g *MarkdownGenerator) Generate(data RunbookData) string {
 	var sb strings.Builder
@@ -76,10 +96,26 @@ func (g *MarkdownGenerator) Generate(data RunbookData) string {
 		sb.WriteString("\n")
 	}
 
+	// Variables standing in for redacted secrets
+	if variables := usedVariables(data.Variables, data.Groups); len(variables) > 0 {
+		sb.WriteString("## Variables you must set\n\n")
+		sb.WriteString("Secrets in these commands were replaced with variables. Set them before running the steps.\n\n")
+		for _, v := range variables {
+			sb.WriteString(fmt.Sprintf("- `%s` (found by `%s`)\n", v.Reference(), v.Pattern))
+		}
+		sb.WriteString("\n")
+	}
+
 	// Steps
 	sb.WriteString("## Steps\n\n")
+	sources := groupSources(data.Groups)
+	dirs := make(map[string]*dirTracker) // by source: each machine has its own cwd
 	for i, group := range data.Groups {
-		sb.WriteString(g.generateStep(i+1, group))
+		source := group.Commands[0].Source
+		if dirs[source] == nil {
+			dirs[source] = &dirTracker{}
+		}
+		sb.WriteString(g.generateStep(i+1, group, dirs[source], len(sources) > 1))
 		sb.WriteString("\n")
 	}
 
@@ -89,6 +125,9 @@ func (g *MarkdownGenerator) Generate(data RunbookData) string {
 	if data.TimeRange != "" {
 		sb.WriteString(fmt.Sprintf("- Time range: %s\n", data.TimeRange))
 	}
+	if len(sources) > 1 {
+		sb.WriteString(fmt.Sprintf("- Machines: %s\n", strings.Join(sources, ", ")))
+	}
 	if data.RedactedCount > 0 {
 		sb.WriteString(fmt.Sprintf("- Commands sanitized: %d\n", data.RedactedCount))
 	}
@@ -139,8 +178,63 @@ func (g *MarkdownGenerator) generateOverview(data RunbookData) string {
 	return strings.Join(parts, " ")
 }
 
-// generateStep creates markdown for a single step.
-func (g *MarkdownGenerator) generateStep(num int, group processor.CommandGroup) string {
+// usedVariables returns the variables some command in groups still refers
+// to; those of commands dropped as duplicates are not needed.
+func usedVariables(variables []processor.Variable, groups []processor.CommandGroup) []processor.Variable {
+	var used []processor.Variable
+	for _, v := range variables {
+	search:
+		for _, group := range groups {
+			for _, cmd := range group.Commands {
+				if strings.Contains(cmd.Command, v.Reference()) {
+					used = append(used, v)
+					break search
+				}
+			}
+		}
+	}
+	return used
+}
+
+// dirTracker follows the working directory across steps so a `cd` line is
+// only printed when the directory actually changes.
+type dirTracker struct {
+	current string
+	afterCd bool // previous command was a cd, so the next directory is expected
+}
+
+// change returns the directory to cd into before running cmd, or "" if none
+// is needed.
+func (d *dirTracker) change(cmd history.Entry) string {
+	var target string
+	if cmd.Dir != "" && cmd.Dir != d.current {
+		if !d.afterCd {
+			target = cmd.Dir
+		}
+		d.current = cmd.Dir
+	}
+	d.afterCd = extractTool(cmd.Command) == "cd"
+	return target
+}
+
+// groupSources lists the distinct history sources in order of first use.
+func groupSources(groups []processor.CommandGroup) []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for _, cmd := range group.Commands {
+			if cmd.Source != "" && !seen[cmd.Source] {
+				seen[cmd.Source] = true
+				sources = append(sources, cmd.Source)
+			}
+		}
+	}
+	return sources
+}
+
+// generateStep creates markdown for a single step. When the runbook merges
+// several machines, showHost names the one the step ran on.
+func (g *MarkdownGenerator) generateStep(num int, group processor.CommandGroup, dirs *dirTracker, showHost bool) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("### Step %d: %s\n\n", num, group.Title))
@@ -150,16 +244,38 @@ func (g *MarkdownGenerator) generateStep(num int, group processor.CommandGroup)
 		sb.WriteString("\n\n")
 	}
 
+	if showHost && len(group.Commands) > 0 {
+		sb.WriteString(fmt.Sprintf("**On:** `%s`\n\n", group.Commands[0].Source))
+	}
+
 	sb.WriteString("```bash\n")
 	for _, cmd := range group.Commands {
+		if dir := dirs.change(cmd); dir != "" && g.includeDirs {
+			sb.WriteString(fmt.Sprintf("cd %s\n", shellQuote(dir)))
+		}
 		if g.includeTimestamps && cmd.HasTime {
 			sb.WriteString(fmt.Sprintf("# %s\n", cmd.Timestamp.Format("15:04:05")))
 		}
+		if g.longRunning > 0 && cmd.Duration >= g.longRunning {
+			sb.WriteString(fmt.Sprintf("# this took ~%s\n", formatDuration(cmd.Duration)))
+		}
 		sb.WriteString(cmd.Command)
 		sb.WriteString("\n")
 	}
 	sb.WriteString("```\n")
 
+	if paths := groupPaths(group); len(paths) > 0 {
+		sb.WriteString("\n**Files:** `" + strings.Join(paths, "`, `") + "`\n")
+	}
+
+	if g.outputLines > 0 && group.Output != "" {
+		output := truncateLines(group.Output, g.outputLines)
+		fence := codeFence(output)
+		sb.WriteString("\n<details>\n<summary>Expected output</summary>\n\n")
+		sb.WriteString(fence + "text\n" + output + "\n" + fence + "\n")
+		sb.WriteString("\n</details>\n")
+	}
+
 	// Add "Why" section if we have an intent explanation
 	if group.Intent != "" {
 		sb.WriteString("\n**Why:** ")
@@ -170,6 +286,46 @@ func (g *MarkdownGenerator) generateStep(num int, group processor.CommandGroup)
 	return sb.String()
 }
 
+// groupPaths returns the paths a step's commands referenced, as recorded by
+// shells such as fish, without repeats.
+func groupPaths(group processor.CommandGroup) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, cmd := range group.Commands {
+		for _, path := range cmd.Paths {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// truncateLines keeps the first n lines of s, noting how many were dropped.
+func truncateLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n... (%d more lines)", len(lines)-n)
+}
+
+// codeFence returns a backtick fence longer than any backtick run in content,
+// so output containing ``` cannot end the block early.
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
 // inferPrerequisites determines what tools/access are needed.
 func (g *MarkdownGenerator) inferPrerequisites(groups []processor.CommandGroup) []string {
 	tools := make(map[string]bool)
@@ -246,6 +402,28 @@ func extractTool(command string) string {
 	return tool
 }
 
+// shellQuote quotes a path for use in a shell command when it needs it.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-~+@%:,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// formatDuration renders a duration in rounded human units (e.g. "12 min").
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%.1f h", d.Hours())
+	case d >= time.Minute:
+		return fmt.Sprintf("%d min", int(d.Round(time.Minute).Minutes()))
+	default:
+		return fmt.Sprintf("%d s", int(d.Round(time.Second).Seconds()))
+	}
+}
+
 // formatIntent converts an intent name to a readable string.
 func formatIntent(intent string) string {
 	replacements := map[string]string{
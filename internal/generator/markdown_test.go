@@ -0,0 +1,247 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrf/runbook-generator/internal/history"
+	"github.com/mrf/runbook-generator/internal/processor"
+)
+
+func TestMarkdownGenerator_LongRunningMarker(t *testing.T) {
+	gen := NewMarkdownGenerator()
+
+	group := processor.CommandGroup{
+		Title: "Build",
+		Commands: []history.Entry{
+			{Number: 1, Command: "make release", Duration: 12 * time.Minute},
+			{Number: 2, Command: "ls", Duration: 2 * time.Second},
+		},
+	}
+
+	output := gen.generateStep(1, group, &dirTracker{}, false)
+
+	if !strings.Contains(output, "# this took ~12 min\nmake release\n") {
+		t.Errorf("expected long-running marker before command, got:\n%s", output)
+	}
+
+	if strings.Count(output, "this took") != 1 {
+		t.Errorf("expected only one long-running marker, got:\n%s", output)
+	}
+}
+
+func TestMarkdownGenerator_LongRunningDisabled(t *testing.T) {
+	gen := NewMarkdownGenerator().WithLongRunningThreshold(0)
+
+	group := processor.CommandGroup{
+		Title:    "Build",
+		Commands: []history.Entry{{Number: 1, Command: "make release", Duration: time.Hour}},
+	}
+
+	if output := gen.generateStep(1, group, &dirTracker{}, false); strings.Contains(output, "this took") {
+		t.Errorf("expected no marker when disabled, got:\n%s", output)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{45 * time.Second, "45 s"},
+		{12*time.Minute + 20*time.Second, "12 min"},
+		{90 * time.Minute, "1.5 h"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDuration(tt.in); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownGenerator_DirectoryContext(t *testing.T) {
+	gen := NewMarkdownGenerator()
+
+	group := processor.CommandGroup{
+		Title: "Build",
+		Commands: []history.Entry{
+			{Number: 1, Command: "git pull", Dir: "/srv/app"},
+			{Number: 2, Command: "cd web", Dir: "/srv/app"},
+			{Number: 3, Command: "npm install", Dir: "/srv/app/web"},
+			{Number: 4, Command: "ls", Dir: "/tmp/my build"},
+		},
+	}
+
+	output := gen.generateStep(1, group, &dirTracker{}, false)
+
+	expected := "```bash\ncd /srv/app\ngit pull\ncd web\nnpm install\ncd '/tmp/my build'\nls\n```"
+	if !strings.Contains(output, expected) {
+		t.Errorf("expected cd context:\n%s\ngot:\n%s", expected, output)
+	}
+
+	if output := NewMarkdownGenerator().WithDirectories(false).generateStep(1, group, &dirTracker{}, false); strings.Contains(output, "cd /srv/app") {
+		t.Errorf("expected no cd context when disabled, got:\n%s", output)
+	}
+}
+
+func TestMarkdownGenerator_MultipleMachines(t *testing.T) {
+	data := RunbookData{
+		Title: "Incident",
+		Groups: []processor.CommandGroup{
+			{Title: "Check pods", Commands: []history.Entry{{Command: "kubectl get pods", Dir: "/work", Source: "laptop"}}},
+			{Title: "Restart", Commands: []history.Entry{{Command: "systemctl restart api", Dir: "/srv", Source: "bastion"}}},
+			{Title: "Verify", Commands: []history.Entry{{Command: "kubectl get pods", Dir: "/work", Source: "laptop"}}},
+		},
+	}
+
+	output := NewMarkdownGenerator().Generate(data)
+
+	for _, want := range []string{
+		"### Step 1: Check pods\n\n**On:** `laptop`\n",
+		"### Step 2: Restart\n\n**On:** `bastion`\n",
+		"- Machines: laptop, bastion\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output, got:\n%s", want, output)
+		}
+	}
+
+	// Each machine keeps its own working directory
+	if strings.Count(output, "cd /work") != 1 {
+		t.Errorf("expected a single cd /work, got:\n%s", output)
+	}
+}
+
+func TestMarkdownGenerator_Scrubbed(t *testing.T) {
+	entries := []history.Entry{
+		{Number: 1, Command: "git pull", Dir: "/home/alice/src/app", GitBranch: "fix/alice@db1.corp.example.com",
+			Host: "db1.corp.example.com", Source: "db1.corp.example.com", Paths: []string{"/home/alice/src/app/go.mod"}},
+		{Number: 2, Command: "ssh alice@web2.corp.example.com", Dir: "/home/alice",
+			Host: "web2.corp.example.com", Source: "web2.corp.example.com", Output: "Last login from 192.168.4.20"},
+		{Number: 3, Command: "systemctl restart api", Dir: "/srv",
+			Host: "web2.corp.example.com", Source: "web2.corp.example.com"},
+	}
+
+	sanitizer := processor.NewSanitizer().WithScrubbing(processor.ScrubConfig{
+		Enabled:         true,
+		InternalDomains: []string{"corp.example.com"},
+	})
+	sanitized, _ := sanitizer.Process(entries)
+
+	output := NewMarkdownGenerator().WithOutputLines(5).Generate(RunbookData{
+		Title:  "Deploy",
+		Groups: processor.NewIntentAnalyzer().Analyze(sanitized),
+	})
+
+	for _, original := range []string{"alice", "db1", "web2", "corp.example.com", "192.168.4.20"} {
+		if strings.Contains(output, original) {
+			t.Errorf("expected %q scrubbed from the runbook, got:\n%s", original, output)
+		}
+	}
+	for _, want := range []string{"cd /home/user-1/src/app\n", "- Machines: host-2, host-1\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestMarkdownGenerator_ExpectedOutput(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line "+strings.Repeat("x", i))
+	}
+
+	group := processor.CommandGroup{
+		Title:    "Check pods",
+		Commands: []history.Entry{{Number: 1, Command: "kubectl get pods"}},
+		Output:   strings.Join(lines, "\n"),
+	}
+
+	output := NewMarkdownGenerator().WithOutputLines(3).generateStep(1, group, &dirTracker{}, false)
+
+	expected := "```\n\n<details>\n<summary>Expected output</summary>\n\n```text\nline x\nline xx\nline xxx\n... (17 more lines)\n```\n\n</details>\n"
+	if !strings.Contains(output, expected) {
+		t.Errorf("expected truncated output block, got:\n%s", output)
+	}
+
+	if output := NewMarkdownGenerator().WithOutputLines(0).generateStep(1, group, &dirTracker{}, false); strings.Contains(output, "Expected output") {
+		t.Errorf("expected no output block when disabled, got:\n%s", output)
+	}
+}
+
+func TestMarkdownGenerator_Paths(t *testing.T) {
+	group := processor.CommandGroup{
+		Title: "Edit config",
+		Commands: []history.Entry{
+			{Number: 1, Command: "vim config.toml", Paths: []string{"config.toml"}},
+			{Number: 2, Command: "cp config.toml config.toml.bak", Paths: []string{"config.toml", "config.toml.bak"}},
+		},
+	}
+
+	output := NewMarkdownGenerator().generateStep(1, group, &dirTracker{}, false)
+
+	if !strings.Contains(output, "```\n\n**Files:** `config.toml`, `config.toml.bak`\n") {
+		t.Errorf("expected the referenced files listed under the commands, got:\n%s", output)
+	}
+
+	group.Commands = []history.Entry{{Number: 1, Command: "ls"}}
+	if output := NewMarkdownGenerator().generateStep(1, group, &dirTracker{}, false); strings.Contains(output, "**Files:**") {
+		t.Errorf("expected no files note without paths, got:\n%s", output)
+	}
+}
+
+func TestMarkdownGenerator_Variables(t *testing.T) {
+	data := RunbookData{
+		Title: "Deploy",
+		Groups: []processor.CommandGroup{{
+			Title: "Log in",
+			Commands: []history.Entry{
+				{Number: 1, Command: `export DB_PASSWORD="${DB_PASSWORD}"`},
+				{Number: 2, Command: `psql --password="${DB_PASSWORD}" -h db`},
+			},
+		}},
+		Variables: []processor.Variable{
+			{Name: "DB_PASSWORD", Pattern: "secret-env"},
+			{Name: "TOKEN", Pattern: "token-flag"}, // only in a dropped command
+		},
+	}
+
+	output := NewMarkdownGenerator().Generate(data)
+
+	if !strings.Contains(output, "## Variables you must set\n") {
+		t.Fatalf("expected a variables section, got:\n%s", output)
+	}
+	if !strings.Contains(output, "- `${DB_PASSWORD}` (found by `secret-env`)\n") {
+		t.Errorf("expected DB_PASSWORD listed with its pattern, got:\n%s", output)
+	}
+	if strings.Contains(output, "TOKEN") {
+		t.Errorf("expected unused variables left out, got:\n%s", output)
+	}
+	if strings.Index(output, "## Variables") > strings.Index(output, "## Steps") {
+		t.Errorf("expected the variables before the steps, got:\n%s", output)
+	}
+
+	data.Variables = nil
+	if output := NewMarkdownGenerator().Generate(data); strings.Contains(output, "## Variables") {
+		t.Errorf("expected no variables section without variables, got:\n%s", output)
+	}
+}
+
+func TestCodeFence(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"plain", "```"},
+		{"a ``` fence", "````"},
+		{"`````", "``````"},
+	}
+
+	for _, tt := range tests {
+		if got := codeFence(tt.content); got != tt.expected {
+			t.Errorf("codeFence(%q) = %q, want %q", tt.content, got, tt.expected)
+		}
+	}
+}
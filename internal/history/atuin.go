@@ -0,0 +1,114 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// sqliteHeader is the magic string at the start of every SQLite database.
+const sqliteHeader = "SQLite format 3\x00"
+
+// atuinQuery selects live history rows in execution order. Atuin stores
+// timestamps and durations in nanoseconds, with -1 for an unknown duration.
+const atuinQuery = `SELECT timestamp, duration, exit, command, cwd, session, hostname
+FROM history
+WHERE deleted_at IS NULL AND (? = '' OR session = ?)
+ORDER BY timestamp`
+
+// atuinDefaultPath returns atuin's default database location.
+func atuinDefaultPath(home string) string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "atuin", "history.db")
+}
+
+// NewAtuinExtractor creates a history extractor reading an atuin database.
+// An empty dbPath uses atuin's default location. The database is opened
+// read-only. Commands are numbered from 1 in execution order; when a session
+// is given, only that session's commands are read and numbered.
+func NewAtuinExtractor(dbPath, session string) (*Extractor, error) {
+	if dbPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dbPath = atuinDefaultPath(home)
+	}
+
+	extractor, err := NewFileExtractor(dbPath, FormatAtuin)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractor.WithSession(session), nil
+}
+
+// scanAtuin reads atuin history from its SQLite database.
+func scanAtuin(dbPath, session string, emit func(Entry) bool) error {
+	// A file: URI path has to be absolute, or ./history.db reads as a host
+	abs, err := filepath.Abs(dbPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreadableFile, err)
+	}
+	dsn := (&url.URL{Scheme: "file", Path: abs, RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreadableFile, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.Query(atuinQuery, session, session)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreadableFile, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	commandNumber := 0
+	for rows.Next() {
+		var (
+			timestamp, duration, exit  int64
+			command, cwd, sess, hostID string
+		)
+		if err := rows.Scan(&timestamp, &duration, &exit, &command, &cwd, &sess, &hostID); err != nil {
+			return err
+		}
+
+		commandNumber++
+
+		entry := Entry{
+			Number:      commandNumber,
+			Timestamp:   time.Unix(0, timestamp),
+			Command:     command,
+			HasTime:     true,
+			Dir:         cwd,
+			ExitCode:    int(exit),
+			HasExitCode: exit >= 0, // atuin stores -1 until the command finishes
+			Host:        atuinHost(hostID),
+			Session:     sess,
+		}
+		if duration > 0 {
+			entry.Duration = time.Duration(duration)
+		}
+
+		if !emit(entry) {
+			return nil
+		}
+	}
+
+	return rows.Err()
+}
+
+// atuinHost strips the user from atuin's "hostname:username" host field.
+func atuinHost(hostID string) string {
+	host, _, _ := strings.Cut(hostID, ":")
+	return host
+}
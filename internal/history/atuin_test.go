@@ -0,0 +1,163 @@
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAtuin_Extract(t *testing.T) {
+	extractor := createTestAtuinExtractor(t, "")
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Deleted row is skipped
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Number != 1 || first.Command != "cd ~/src/app" {
+		t.Errorf("expected #1 'cd ~/src/app', got #%d %q", first.Number, first.Command)
+	}
+	if !first.HasTime || first.Timestamp.Unix() != 1699000000 {
+		t.Errorf("expected timestamp 1699000000, got %d", first.Timestamp.Unix())
+	}
+	if first.Dir != "/home/dev" || first.Host != "laptop" || first.Session != "s1" {
+		t.Errorf("unexpected metadata: dir=%q host=%q session=%q", first.Dir, first.Host, first.Session)
+	}
+	if first.Duration != 0 {
+		t.Errorf("expected unknown duration to be zero, got %v", first.Duration)
+	}
+
+	failed := entries[1]
+	if !failed.HasExitCode || failed.ExitCode != 2 {
+		t.Errorf("expected exit code 2, got %d (HasExitCode=%v)", failed.ExitCode, failed.HasExitCode)
+	}
+	if failed.Duration != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s duration, got %v", failed.Duration)
+	}
+}
+
+func TestAtuin_Extract_Session(t *testing.T) {
+	extractor := createTestAtuinExtractor(t, "s2")
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	if entries[0].Number != 1 || entries[0].Command != "kubectl get pods" {
+		t.Errorf("expected #1 'kubectl get pods', got #%d %q", entries[0].Number, entries[0].Command)
+	}
+
+	// atuin records -1 while a command runs or when its status is unknown
+	if entries[0].HasExitCode {
+		t.Errorf("expected exit code -1 treated as unknown, got %d", entries[0].ExitCode)
+	}
+}
+
+func TestAtuin_Extract_RelativePath(t *testing.T) {
+	dbPath := createTestAtuinDB(t)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Dir(dbPath)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	extractor, err := NewAtuinExtractor("./history.db", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected 3 entries, got %d", len(entries))
+	}
+}
+
+func TestAtuin_Extract_WrongSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE other (id integer)`); err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	_ = db.Close()
+
+	extractor, err := NewAtuinExtractor(dbPath, "")
+	if err == nil {
+		_, err = extractor.Extract(1, 10)
+	}
+	if !errors.Is(err, ErrUnreadableFile) || !strings.Contains(err.Error(), "no such table") {
+		t.Errorf("expected an unreadable file error naming the missing table, got %v", err)
+	}
+}
+
+// createTestAtuinExtractor creates an atuin database with a few rows.
+func createTestAtuinExtractor(t *testing.T, session string) *Extractor {
+	t.Helper()
+
+	extractor, err := NewAtuinExtractor(createTestAtuinDB(t), session)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return extractor
+}
+
+// createTestAtuinDB writes an atuin database with a few rows and returns its
+// path.
+func createTestAtuinDB(t *testing.T) string {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	statements := []string{
+		`CREATE TABLE history (
+			id text primary key,
+			timestamp integer not null,
+			duration integer not null,
+			exit integer not null,
+			command text not null,
+			cwd text not null,
+			session text not null,
+			hostname text not null,
+			deleted_at integer
+		)`,
+		`INSERT INTO history VALUES ('a', 1699000000000000000, -1, 0, 'cd ~/src/app', '/home/dev', 's1', 'laptop:dev', NULL)`,
+		`INSERT INTO history VALUES ('b', 1699000010000000000, 1500000000, 2, 'make tset', '/home/dev/src/app', 's1', 'laptop:dev', NULL)`,
+		`INSERT INTO history VALUES ('c', 1699000015000000000, 100, 0, 'rm secrets.txt', '/home/dev/src/app', 's1', 'laptop:dev', 1699000020000000000)`,
+		`INSERT INTO history VALUES ('d', 1699000020000000000, 2000000, -1, 'kubectl get pods', '/home/dev', 's2', 'bastion:ops', NULL)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to set up database: %v", err)
+		}
+	}
+	return dbPath
+}
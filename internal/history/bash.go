@@ -0,0 +1,78 @@
+package history
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bashTimestampPattern matches the comment lines bash writes before each
+// command when HISTTIMEFORMAT is set: #1699000000
+var bashTimestampPattern = regexp.MustCompile(`^#([0-9]+)$`)
+
+// parseBash reads bash history, with or without HISTTIMEFORMAT timestamps.
+//
+// Without timestamps every non-empty line is one command. Once a timestamp
+// comment has been seen, all lines up to the next timestamp belong to the
+// same command, which is how bash itself reads multi-line entries back.
+// Numbering matches bash's `history` builtin.
+func parseBash(lines *lineReader, commandNumber int, emit emitFunc) error {
+	var (
+		pending   []string
+		start     int64 // offset of the pending command's first line or timestamp
+		timestamp time.Time
+		hasTime   bool
+	)
+
+	flush := func() bool {
+		if len(pending) == 0 {
+			return true
+		}
+		commandNumber++
+		entry := Entry{
+			Number:    commandNumber,
+			Timestamp: timestamp,
+			Command:   strings.Join(pending, "\n"),
+			HasTime:   hasTime,
+		}
+		pending = nil
+		return emit(entry, start)
+	}
+
+	for lines.Scan() {
+		line := lines.Text()
+
+		if matches := bashTimestampPattern.FindStringSubmatch(line); matches != nil {
+			if !flush() {
+				return nil
+			}
+			ts, _ := strconv.ParseInt(matches[1], 10, 64)
+			timestamp = time.Unix(ts, 0)
+			hasTime = true
+			start = lines.Offset()
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if !hasTime {
+			start = lines.Offset()
+		}
+		pending = append(pending, line)
+
+		// Untimestamped history has exactly one command per line
+		if !hasTime && !flush() {
+			return nil
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		return err
+	}
+
+	flush()
+	return nil
+}
@@ -0,0 +1,118 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBash_Extract_PlainHistory(t *testing.T) {
+	content := `ls -la
+cd /tmp
+
+git status
+`
+	extractor := createTestExtractorForFormat(t, FormatBash, content)
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"ls -la", "cd /tmp", "git status"}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+	}
+
+	for i, exp := range expected {
+		if entries[i].Command != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, entries[i].Command)
+		}
+		if entries[i].Number != i+1 {
+			t.Errorf("entry %d: expected number %d, got %d", i, i+1, entries[i].Number)
+		}
+		if entries[i].HasTime {
+			t.Errorf("entry %d: expected HasTime=false", i)
+		}
+	}
+}
+
+func TestBash_Extract_Timestamps(t *testing.T) {
+	content := `#1699000000
+first
+#1699000010
+second
+#1699000020
+third
+`
+	extractor := createTestExtractorForFormat(t, FormatBash, content)
+
+	entries, err := extractor.Extract(2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Command != "second" || entries[0].Number != 2 {
+		t.Errorf("expected #2 'second', got #%d %q", entries[0].Number, entries[0].Command)
+	}
+
+	if !entries[0].HasTime || entries[0].Timestamp.Unix() != 1699000010 {
+		t.Errorf("expected timestamp 1699000010, got %d (HasTime=%v)", entries[0].Timestamp.Unix(), entries[0].HasTime)
+	}
+}
+
+func TestBash_Extract_TimestampedMultiLine(t *testing.T) {
+	content := `#1699000000
+for f in *.log; do
+  gzip "$f"
+done
+#1699000010
+ls
+`
+	extractor := createTestExtractorForFormat(t, FormatBash, content)
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	expected := "for f in *.log; do\n  gzip \"$f\"\ndone"
+	if entries[0].Command != expected {
+		t.Errorf("expected %q, got %q", expected, entries[0].Command)
+	}
+
+	if entries[1].Number != 2 {
+		t.Errorf("expected second entry to be #2, got #%d", entries[1].Number)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("bash"); err != nil || f != FormatBash {
+		t.Errorf("expected FormatBash, got %q (%v)", f, err)
+	}
+
+	if _, err := ParseFormat("tcsh"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+// createTestExtractorForFormat creates an extractor for the given format with a temp history file.
+func createTestExtractorForFormat(t *testing.T, format Format, content string) *Extractor {
+	t.Helper()
+
+	tmpFile := filepath.Join(t.TempDir(), "history")
+
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+
+	return &Extractor{filePath: tmpFile, format: format}
+}
@@ -2,10 +2,20 @@ package history
 
 import "time"
 
-// Entry represents a single command from zsh history.
+// Entry represents a single command from shell history.
 type Entry struct {
-	Number    int       // Command number (matches `history` output)
-	Timestamp time.Time // When the command was executed
-	Command   string    // The command itself
-	HasTime   bool      // Whether timestamp was parsed successfully
+	Number      int           // Command number (matches `history` output)
+	Timestamp   time.Time     // When the command was executed
+	Command     string        // The command itself
+	HasTime     bool          // Whether timestamp was parsed successfully
+	Duration    time.Duration // How long the command ran (zsh extended history), zero if unknown
+	Paths       []string      // Paths the command referenced, when the shell records them (fish)
+	Dir         string        // Working directory the command ran in, empty if unknown
+	ExitCode    int           // Exit status of the command
+	HasExitCode bool          // Whether ExitCode was recorded by the source
+	Host        string        // Machine the command ran on, empty if unknown
+	Session     string        // Terminal session identifier, empty if unknown
+	GitBranch   string        // Git branch checked out in Dir, empty if unknown
+	Source      string        // Label of the history input, set when several are merged
+	Output      string        // What the command printed, when the source captured it (transcripts)
 }
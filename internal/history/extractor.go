@@ -2,64 +2,481 @@ package history
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	ErrHistoryNotFound = errors.New("zsh history file not found at ~/.zsh_history")
+	ErrHistoryNotFound = errors.New("history file not found")
 	ErrInvalidRange    = errors.New("invalid range: 'from' must be less than or equal to 'to'")
+	ErrInvalidWindow   = errors.New("invalid time window: 'since' must not be after 'until'")
 	ErrEmptyResult     = errors.New("no commands found in specified range")
 	ErrUnreadableFile  = errors.New("cannot read history file")
+	ErrUnknownFormat   = errors.New("unknown history format")
 )
 
-// zshPattern matches zsh extended history format: : 1234567890:0;command
+// Format identifies the on-disk layout of a history file.
+type Format string
+
+const (
+	FormatZsh   Format = "zsh"
+	FormatBash  Format = "bash"
+	FormatFish  Format = "fish"
+	FormatAtuin Format = "atuin"
+	FormatHook  Format = "hook"
+
+	// Terminal transcripts: commands are recovered by prompt detection
+	FormatScript    Format = "script"
+	FormatAsciinema Format = "asciinema"
+)
+
+// Formats lists every supported history format.
+func Formats() []Format {
+	return []Format{FormatZsh, FormatBash, FormatFish, FormatAtuin, FormatHook, FormatScript, FormatAsciinema}
+}
+
+// transcript reports whether the format is a terminal recording rather than
+// a history file.
+func (f Format) transcript() bool {
+	return f == FormatScript || f == FormatAsciinema
+}
+
+// ParseFormat converts a shell name into a Format.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats() {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+// SniffFormat guesses the format of history content from its first
+// non-empty line, for history files whose origin shell is unknown.
+func SniffFormat(head []byte) Format {
+	if bytes.HasPrefix(head, []byte(sqliteHeader)) {
+		return FormatAtuin
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(head))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case zshPattern.MatchString(line):
+			return FormatZsh
+		case strings.HasPrefix(line, scriptHeader):
+			return FormatScript
+		case isCastHeader(line):
+			return FormatAsciinema
+		case strings.HasPrefix(line, "- cmd: "):
+			return FormatFish
+		case strings.HasPrefix(line, "{"):
+			return FormatHook
+		default:
+			return FormatBash
+		}
+	}
+	return FormatZsh
+}
+
+// DetectFormat guesses the history format from the user's login shell ($SHELL),
+// falling back to zsh.
+func DetectFormat() Format {
+	if f, err := ParseFormat(filepath.Base(os.Getenv("SHELL"))); err == nil {
+		return f
+	}
+	return FormatZsh
+}
+
+// defaultPath returns the usual history file location for the format.
+func (f Format) defaultPath(home string) string {
+	switch f {
+	case FormatBash:
+		return filepath.Join(home, ".bash_history")
+	case FormatFish:
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return filepath.Join(dataHome, "fish", "fish_history")
+	case FormatAtuin:
+		return atuinDefaultPath(home)
+	case FormatHook:
+		return hookLogDefaultPath(home)
+	default:
+		return filepath.Join(home, ".zsh_history")
+	}
+}
+
+// emitFunc receives each parsed entry along with the byte offset its first
+// line starts at. Returning false stops parsing.
+type emitFunc func(entry Entry, offset int64) bool
+
+// parse reads history in this format, calling emit for each command in order.
+// The reader must be positioned at the start of a command; commandNumber is
+// the number of commands before that position.
+func (f Format) parse(lines *lineReader, commandNumber int, emit emitFunc) error {
+	switch f {
+	case FormatBash:
+		return parseBash(lines, commandNumber, emit)
+	case FormatFish:
+		return parseFish(lines, commandNumber, emit)
+	case FormatHook:
+		return parseHookLog(lines, commandNumber, emit)
+	case FormatScript:
+		return parseTypescript(lines, commandNumber, nil, emit)
+	case FormatAsciinema:
+		return parseAsciicast(lines, commandNumber, nil, emit)
+	default:
+		return parseZsh(lines, commandNumber, emit)
+	}
+}
+
+// zshPattern matches zsh extended history format: : <start>:<elapsed>;command
 // Matches same lines as: grep '^: [0-9]*:[0-9]*;'
-var zshPattern = regexp.MustCompile(`^: ([0-9]+):[0-9]+;(.*)$`)
+var zshPattern = regexp.MustCompile(`^: ([0-9]+):([0-9]+);(.*)$`)
+
+// zshMeta is the marker zsh writes before "metafied" bytes in its history
+// file. The byte that follows has been XORed with 32.
+const zshMeta = 0x83
+
+// unmetafy decodes a line of zsh history back into the original bytes, so
+// UTF-8 text and control characters come through intact.
+func unmetafy(line []byte) string {
+	if bytes.IndexByte(line, zshMeta) < 0 {
+		return string(line)
+	}
+
+	out := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] == zshMeta && i+1 < len(line) {
+			i++
+			out = append(out, line[i]^32)
+			continue
+		}
+		out = append(out, line[i])
+	}
+	return string(out)
+}
 
-// Extractor reads and parses zsh history.
+// Extractor reads and parses shell history.
 type Extractor struct {
 	filePath string
+	data     []byte // history read from an io.Reader; used instead of filePath when set
+	format   Format
+	session  string           // restricts atuin history to a single terminal session
+	indexDir string           // where line indexes of large files are cached; "" keeps them in memory
+	prompts  []*regexp.Regexp // prompt patterns for transcripts; nil uses DefaultPrompts
+	idx      *lineIndex
 }
 
 // NewExtractor creates a new history extractor using ~/.zsh_history.
 func NewExtractor() (*Extractor, error) {
+	return NewExtractorForFormat(FormatZsh)
+}
+
+// NewExtractorForFormat creates a history extractor reading the default
+// history file for the given format. For zsh and bash, $HISTFILE is honoured
+// when it belongs to the user's login shell; otherwise the usual location is
+// used (e.g. ~/.bash_history for bash, ~/.local/share/fish/fish_history for
+// fish).
+func NewExtractorForFormat(format Format) (*Extractor, error) {
+	if format.transcript() {
+		return nil, fmt.Errorf("%s transcripts have no default location; give the file to read", format)
+	}
+
+	if histFile := os.Getenv("HISTFILE"); histFile != "" && (format == FormatZsh || format == FormatBash) && format == DetectFormat() {
+		return NewFileExtractor(histFile, format)
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
 	}
 
-	filePath := filepath.Join(home, ".zsh_history")
+	return NewFileExtractor(format.defaultPath(home), format)
+}
+
+// NewFileExtractor creates a history extractor reading an explicit file,
+// such as a history file copied from another machine.
+func NewFileExtractor(filePath string, format Format) (*Extractor, error) {
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, ErrHistoryNotFound
+		return nil, fmt.Errorf("%w at %s", ErrHistoryNotFound, filePath)
+	}
+
+	return &Extractor{filePath: filePath, format: format, indexDir: defaultIndexDir()}, nil
+}
+
+// NewReaderExtractor creates a history extractor from an io.Reader such as
+// stdin. The reader is consumed immediately so the history can be scanned
+// more than once.
+func NewReaderExtractor(r io.Reader, format Format) (*Extractor, error) {
+	if format == FormatAtuin {
+		return nil, fmt.Errorf("%w: atuin history must be read from its database file", ErrUnknownFormat)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadableFile, err)
+	}
+
+	return &Extractor{data: data, format: format}, nil
+}
+
+// WithSession restricts history to a single terminal session, so a runbook
+// comes from exactly one shell. Commands are renumbered within the session.
+// Only sources that record sessions (atuin, the hook log) have any matches.
+func (e *Extractor) WithSession(session string) *Extractor {
+	e.session = session
+	return e
+}
+
+// WithPrompt sets the pattern that recognises shell prompts in transcripts.
+// The command is taken from a group named "cmd", or the last group; optional
+// "host" and "dir" groups are recorded too.
+func (e *Extractor) WithPrompt(pattern string) (*Extractor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt pattern %q: %w", pattern, err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("invalid prompt pattern %q: needs a group capturing the command", pattern)
+	}
+	e.prompts = []*regexp.Regexp{re}
+	return e, nil
+}
+
+// Path returns the history file being read, or "" for reader-backed history.
+func (e *Extractor) Path() string {
+	return e.filePath
+}
+
+// Format returns the history format being read.
+func (e *Extractor) Format() Format {
+	return e.format
+}
+
+// Session returns the session the extractor is restricted to, if any.
+func (e *Extractor) Session() string {
+	return e.session
+}
+
+// open returns a fresh reader over the history contents, positioned at byte
+// offset.
+func (e *Extractor) open(offset int64) (io.ReadCloser, error) {
+	if e.data != nil {
+		return io.NopCloser(bytes.NewReader(e.data[offset:])), nil
+	}
+
+	file, err := os.Open(e.filePath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// scan calls emit for each history entry in order until emit returns false.
+func (e *Extractor) scan(emit func(Entry) bool) error {
+	return e.scanFrom(0, 0, emit)
+}
+
+// scanFrom is scan starting at a command boundary found in the line index:
+// offset is where it starts and commandNumber how many commands precede it.
+func (e *Extractor) scanFrom(offset int64, commandNumber int, emit func(Entry) bool) error {
+	if e.format == FormatAtuin {
+		return scanAtuin(e.filePath, e.session, emit)
+	}
+
+	file, err := e.open(offset)
+	if err != nil {
+		return ErrUnreadableFile
 	}
+	defer func() { _ = file.Close() }()
 
-	return &Extractor{filePath: filePath}, nil
+	lines := newLineReader(file, offset)
+
+	parse := e.format.parse
+	if e.prompts != nil {
+		switch e.format {
+		case FormatScript:
+			parse = func(lines *lineReader, n int, emit emitFunc) error {
+				return parseTypescript(lines, n, e.prompts, emit)
+			}
+		case FormatAsciinema:
+			parse = func(lines *lineReader, n int, emit emitFunc) error {
+				return parseAsciicast(lines, n, e.prompts, emit)
+			}
+		}
+	}
+
+	if e.session == "" {
+		return parse(lines, commandNumber, func(entry Entry, _ int64) bool {
+			return emit(entry)
+		})
+	}
+
+	return parse(lines, commandNumber, func(entry Entry, _ int64) bool {
+		if entry.Session != e.session {
+			return true
+		}
+		commandNumber++
+		entry.Number = commandNumber
+		return emit(entry)
+	})
 }
 
 // Extract reads history entries within the specified command number range.
-// Command numbers match what you see in `history` output.
+// Command numbers match what you see in `history` output. Negative numbers
+// count back from the most recent command: -1 is the last command, so
+// Extract(-40, -1) returns the last 40 commands.
 func (e *Extractor) Extract(from, to int) ([]Entry, error) {
+	if from < 0 || to < 0 {
+		total, err := e.Count()
+		if err != nil {
+			return nil, err
+		}
+		from = resolveNumber(from, total)
+		to = resolveNumber(to, total)
+	}
+
 	if from > to {
 		return nil, ErrInvalidRange
 	}
 
-	file, err := os.Open(e.filePath)
+	// Skip straight to the commands wanted when the file is indexed
+	var offset int64
+	var skipped int
+	if e.indexed() && from > 1 {
+		idx, err := e.index()
+		if err != nil {
+			return nil, err
+		}
+		offset, skipped = idx.checkpoint(from)
+	}
+
+	var entries []Entry
+	err := e.scanFrom(offset, skipped, func(entry Entry) bool {
+		// Skip if outside range
+		if entry.Number < from {
+			return true
+		}
+		if entry.Number > to {
+			return false
+		}
+		entries = append(entries, entry)
+		return true
+	})
 	if err != nil {
-		return nil, ErrUnreadableFile
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrEmptyResult
+	}
+
+	return entries, nil
+}
+
+// ExtractLast reads the n most recent history entries.
+func (e *Extractor) ExtractLast(n int) ([]Entry, error) {
+	if n <= 0 {
+		return nil, ErrInvalidRange
+	}
+	return e.Extract(-n, -1)
+}
+
+// Count returns the number of commands in the history file, which is also
+// the number of the most recent command.
+func (e *Extractor) Count() (int, error) {
+	if e.indexed() {
+		idx, err := e.index()
+		if err != nil {
+			return 0, err
+		}
+		return idx.Count, nil
+	}
+
+	total := 0
+	err := e.scan(func(entry Entry) bool {
+		total = entry.Number
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// resolveNumber converts a negative, end-relative command number into an
+// absolute one. Offsets reaching past the first command clamp to 1.
+func resolveNumber(n, total int) int {
+	if n >= 0 {
+		return n
+	}
+	n = total + n + 1
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// ExtractTimeRange reads history entries executed between since and until
+// (inclusive). A zero since or until leaves that end of the window open.
+// Entries without a timestamp are never included.
+func (e *Extractor) ExtractTimeRange(since, until time.Time) ([]Entry, error) {
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		return nil, ErrInvalidWindow
 	}
-	defer func() { _ = file.Close() }()
 
 	var entries []Entry
-	scanner := bufio.NewScanner(file)
-	commandNumber := 0
+	err := e.scan(func(entry Entry) bool {
+		if !entry.HasTime {
+			return true
+		}
+		if !since.IsZero() && entry.Timestamp.Before(since) {
+			return true
+		}
+		if !until.IsZero() && entry.Timestamp.After(until) {
+			return true
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	if len(entries) == 0 {
+		return nil, ErrEmptyResult
+	}
+
+	return entries, nil
+}
+
+// parseZsh reads zsh extended history.
+//
+// zsh writes each newline inside a command as a backslash at the end of the
+// line, so a line ending in a backslash continues onto the next one. Those
+// continuation lines are folded back into a single command and do not count
+// towards command numbering, matching zsh's `history` output.
+func parseZsh(lines *lineReader, commandNumber int, emit emitFunc) error {
+	for lines.Scan() {
+		line := unmetafy(lines.Bytes())
 
 		// Only process lines matching zsh history format
 		matches := zshPattern.FindStringSubmatch(line)
@@ -68,33 +485,27 @@ func (e *Extractor) Extract(from, to int) ([]Entry, error) {
 		}
 
 		commandNumber++
+		offset := lines.Offset()
 
-		// Skip if outside range
-		if commandNumber < from {
-			continue
-		}
-		if commandNumber > to {
-			break
+		command := matches[3]
+		for strings.HasSuffix(command, `\`) && lines.Scan() {
+			command = strings.TrimSuffix(command, `\`) + "\n" + unmetafy(lines.Bytes())
 		}
 
 		ts, _ := strconv.ParseInt(matches[1], 10, 64)
+		elapsed, _ := strconv.ParseInt(matches[2], 10, 64)
 		entry := Entry{
 			Number:    commandNumber,
 			Timestamp: time.Unix(ts, 0),
-			Command:   matches[2],
+			Command:   command,
 			HasTime:   true,
+			Duration:  time.Duration(elapsed) * time.Second,
 		}
 
-		entries = append(entries, entry)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	if len(entries) == 0 {
-		return nil, ErrEmptyResult
+		if !emit(entry, offset) {
+			return nil
+		}
 	}
 
-	return entries, nil
+	return lines.Err()
 }
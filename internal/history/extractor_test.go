@@ -1,9 +1,12 @@
 package history
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExtractor_Extract_CommandNumbers(t *testing.T) {
@@ -60,12 +63,12 @@ continuation here
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Should get 4 commands (continuation line and empty line skipped)
+	// Should get 4 commands (continuation line joined, empty line skipped)
 	if len(entries) != 4 {
 		t.Fatalf("expected 4 entries, got %d", len(entries))
 	}
 
-	expected := []string{"first", "multi-line\\", "third", "fourth"}
+	expected := []string{"first", "multi-line\ncontinuation here", "third", "fourth"}
 	for i, exp := range expected {
 		if entries[i].Command != exp {
 			t.Errorf("entry %d: expected %q, got %q", i, exp, entries[i].Command)
@@ -76,6 +79,151 @@ continuation here
 	}
 }
 
+func TestExtractor_Extract_MultiLineCommands(t *testing.T) {
+	content := `: 1699000000:0;for f in *.log; do\
+  gzip "$f"\
+done
+: 1699000010:0;cat <<EOF > config.yaml\
+key: value\
+EOF
+: 1699000020:0;docker run \\
+  --rm alpine
+: 1699000030:0;ls
+`
+	extractor := createTestExtractor(t, content)
+
+	entries, err := extractor.Extract(2, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"cat <<EOF > config.yaml\nkey: value\nEOF",
+		"docker run \\\n  --rm alpine",
+		"ls",
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+	}
+
+	for i, exp := range expected {
+		if entries[i].Command != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, entries[i].Command)
+		}
+		if entries[i].Number != i+2 {
+			t.Errorf("entry %d: expected number %d, got %d", i, i+2, entries[i].Number)
+		}
+	}
+}
+
+func TestExtractor_Extract_Metafied(t *testing.T) {
+	// "ls café 🚀" as zsh writes it: the rocket's 0x9f and 0x9a bytes are
+	// stored as 0x83 followed by the byte XOR 32
+	content := ": 1699000000:0;ls caf\xc3\xa9 \xf0\x83\xbf\x83\xba\x80\n" +
+		": 1699000010:0;echo done\n"
+	extractor := createTestExtractor(t, content)
+
+	entries, err := extractor.Extract(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Command != "ls café 🚀" {
+		t.Errorf("expected %q, got %q", "ls café 🚀", entries[0].Command)
+	}
+
+	if entries[1].Command != "echo done" {
+		t.Errorf("expected %q, got %q", "echo done", entries[1].Command)
+	}
+}
+
+func TestExtractor_Extract_LongLines(t *testing.T) {
+	// A pasted command far beyond bufio.Scanner's 64KB line limit
+	long := "echo " + strings.Repeat("x", 200*1024)
+	content := ": 1699000000:0;first\n: 1699000010:0;" + long + "\n: 1699000020:0;third\n"
+	extractor := createTestExtractor(t, content)
+
+	entries, err := extractor.Extract(1, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[1].Command != long {
+		t.Errorf("expected %d-byte command, got %d bytes", len(long), len(entries[1].Command))
+	}
+
+	if entries[2].Command != "third" {
+		t.Errorf("expected %q, got %q", "third", entries[2].Command)
+	}
+}
+
+func TestExtractor_Extract_NegativeOffsets(t *testing.T) {
+	content := `: 1699000000:0;first
+: 1699000010:0;second
+: 1699000020:0;third
+: 1699000030:0;fourth
+: 1699000040:0;fifth
+`
+	extractor := createTestExtractor(t, content)
+
+	tests := []struct {
+		name     string
+		from, to int
+		expected []int
+	}{
+		{"last two", -2, -1, []int{4, 5}},
+		{"absolute to relative", 3, -2, []int{3, 4}},
+		{"clamped to first", -40, -4, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := extractor.Extract(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %d", len(tt.expected), len(entries))
+			}
+			for i, num := range tt.expected {
+				if entries[i].Number != num {
+					t.Errorf("entry %d: expected number %d, got %d", i, num, entries[i].Number)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractor_ExtractLast(t *testing.T) {
+	content := `: 1699000000:0;first
+: 1699000010:0;second
+: 1699000020:0;third
+`
+	extractor := createTestExtractor(t, content)
+
+	entries, err := extractor.ExtractLast(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 || entries[0].Command != "second" || entries[1].Command != "third" {
+		t.Errorf("expected [second third], got %v", entries)
+	}
+
+	if _, err := extractor.ExtractLast(0); err != ErrInvalidRange {
+		t.Errorf("expected ErrInvalidRange, got %v", err)
+	}
+}
+
 func TestExtractor_Extract_InvalidRange(t *testing.T) {
 	content := `: 1699000000:0;first
 `
@@ -122,6 +270,140 @@ func TestExtractor_Extract_HasTimestamp(t *testing.T) {
 	}
 }
 
+func TestExtractor_Extract_Duration(t *testing.T) {
+	content := `: 1699000000:0;ls
+: 1699000010:720;make release
+`
+	extractor := createTestExtractor(t, content)
+
+	entries, err := extractor.Extract(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if entries[0].Duration != 0 {
+		t.Errorf("expected zero duration, got %v", entries[0].Duration)
+	}
+
+	if entries[1].Duration != 12*time.Minute {
+		t.Errorf("expected 12m duration, got %v", entries[1].Duration)
+	}
+}
+
+func TestExtractor_ExtractTimeRange(t *testing.T) {
+	content := `: 1699000000:0;first
+: 1699000100:0;second
+: 1699000200:0;third
+: 1699000300:0;fourth
+`
+	extractor := createTestExtractor(t, content)
+
+	entries, err := extractor.ExtractTimeRange(time.Unix(1699000050, 0), time.Unix(1699000200, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Number != 2 || entries[1].Number != 3 {
+		t.Errorf("expected commands #2 and #3, got #%d and #%d", entries[0].Number, entries[1].Number)
+	}
+
+	// Open-ended window
+	entries, err = extractor.ExtractTimeRange(time.Unix(1699000250, 0), time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Command != "fourth" {
+		t.Errorf("expected only 'fourth', got %v", entries)
+	}
+}
+
+func TestExtractor_ExtractTimeRange_Invalid(t *testing.T) {
+	extractor := createTestExtractor(t, `: 1699000000:0;first
+`)
+
+	_, err := extractor.ExtractTimeRange(time.Unix(1699000100, 0), time.Unix(1699000000, 0))
+	if err != ErrInvalidWindow {
+		t.Errorf("expected ErrInvalidWindow, got %v", err)
+	}
+
+	_, err = extractor.ExtractTimeRange(time.Unix(1700000000, 0), time.Time{})
+	if err != ErrEmptyResult {
+		t.Errorf("expected ErrEmptyResult, got %v", err)
+	}
+}
+
+func TestNewReaderExtractor(t *testing.T) {
+	content := `#1699000000
+ls
+#1699000010
+git status
+`
+	extractor, err := NewReaderExtractor(strings.NewReader(content), FormatBash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Reader-backed extractors can be scanned more than once
+	for i := 0; i < 2; i++ {
+		entries, err := extractor.ExtractLast(1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Command != "git status" || entries[0].Number != 2 {
+			t.Errorf("pass %d: expected #2 'git status', got %v", i, entries)
+		}
+	}
+}
+
+func TestNewFileExtractor_NotFound(t *testing.T) {
+	_, err := NewFileExtractor(filepath.Join(t.TempDir(), "missing"), FormatZsh)
+	if !errors.Is(err, ErrHistoryNotFound) {
+		t.Errorf("expected ErrHistoryNotFound, got %v", err)
+	}
+}
+
+func TestNewExtractorForFormat_HistFile(t *testing.T) {
+	histFile := filepath.Join(t.TempDir(), "custom_history")
+	if err := os.WriteFile(histFile, []byte(": 1699000000:0;from histfile\n"), 0644); err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("HISTFILE", histFile)
+
+	extractor, err := NewExtractorForFormat(FormatZsh)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if extractor.filePath != histFile {
+		t.Errorf("expected HISTFILE %q to be used, got %q", histFile, extractor.filePath)
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected Format
+	}{
+		{": 1699000000:0;ls\n", FormatZsh},
+		{"\n- cmd: ls\n  when: 1699000000\n", FormatFish},
+		{"#1699000000\nls\n", FormatBash},
+		{"ls -la\n", FormatBash},
+	}
+
+	for _, tt := range tests {
+		if got := SniffFormat([]byte(tt.content)); got != tt.expected {
+			t.Errorf("SniffFormat(%q) = %q, want %q", tt.content, got, tt.expected)
+		}
+	}
+}
+
 // createTestExtractor creates an extractor with a temp history file.
 func createTestExtractor(t *testing.T, content string) *Extractor {
 	t.Helper()
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseFish reads fish history, which is stored as pseudo-YAML: each command
+// starts with a "- cmd:" line, followed by an indented "when:" timestamp and
+// an optional "paths:" list of files the command referenced.
+//
+// Fish does not number its history, so commands are numbered from 1 in file
+// order (oldest first).
+func parseFish(lines *lineReader, commandNumber int, emit emitFunc) error {
+	var (
+		current *Entry
+		start   int64 // offset of the current "- cmd:" line
+		inPaths bool
+	)
+
+	flush := func() bool {
+		if current == nil {
+			return true
+		}
+		entry := *current
+		current = nil
+		return emit(entry, start)
+	}
+
+	for lines.Scan() {
+		line := lines.Text()
+
+		switch {
+		case strings.HasPrefix(line, "- cmd: "):
+			if !flush() {
+				return nil
+			}
+			commandNumber++
+			start = lines.Offset()
+			current = &Entry{
+				Number:  commandNumber,
+				Command: unescapeFish(strings.TrimPrefix(line, "- cmd: ")),
+			}
+			inPaths = false
+
+		case current == nil:
+			// Ignore anything before the first command
+
+		case strings.HasPrefix(line, "  when: "):
+			ts, err := strconv.ParseInt(strings.TrimPrefix(line, "  when: "), 10, 64)
+			if err == nil {
+				current.Timestamp = time.Unix(ts, 0)
+				current.HasTime = true
+			}
+			inPaths = false
+
+		case line == "  paths:":
+			inPaths = true
+
+		case inPaths && strings.HasPrefix(line, "    - "):
+			current.Paths = append(current.Paths, unescapeFish(strings.TrimPrefix(line, "    - ")))
+
+		default:
+			inPaths = false
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		return err
+	}
+
+	flush()
+	return nil
+}
+
+// unescapeFish reverses the escaping fish applies when writing history:
+// newlines are stored as \n and backslashes as \\.
+func unescapeFish(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
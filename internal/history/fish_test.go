@@ -0,0 +1,72 @@
+package history
+
+import "testing"
+
+func TestFish_Extract(t *testing.T) {
+	content := `- cmd: cd ~/src
+  when: 1699000000
+  paths:
+    - ~/src
+- cmd: git status
+  when: 1699000010
+- cmd: vim main.go README.md
+  when: 1699000020
+  paths:
+    - main.go
+    - README.md
+`
+	extractor := createTestExtractorForFormat(t, FormatFish, content)
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	expected := []string{"cd ~/src", "git status", "vim main.go README.md"}
+	for i, exp := range expected {
+		if entries[i].Command != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, entries[i].Command)
+		}
+		if entries[i].Number != i+1 {
+			t.Errorf("entry %d: expected number %d, got %d", i, i+1, entries[i].Number)
+		}
+	}
+
+	if entries[1].Timestamp.Unix() != 1699000010 || !entries[1].HasTime {
+		t.Errorf("expected timestamp 1699000010, got %d", entries[1].Timestamp.Unix())
+	}
+
+	if len(entries[1].Paths) != 0 {
+		t.Errorf("expected no paths on entry 2, got %v", entries[1].Paths)
+	}
+
+	if len(entries[2].Paths) != 2 || entries[2].Paths[0] != "main.go" || entries[2].Paths[1] != "README.md" {
+		t.Errorf("expected paths [main.go README.md], got %v", entries[2].Paths)
+	}
+}
+
+func TestFish_Extract_EscapedMultiLine(t *testing.T) {
+	content := `- cmd: for f in *.log\n  gzip $f\nend
+  when: 1699000000
+- cmd: echo C:\\temp
+  when: 1699000010
+`
+	extractor := createTestExtractorForFormat(t, FormatFish, content)
+
+	entries, err := extractor.Extract(1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if entries[0].Command != "for f in *.log\n  gzip $f\nend" {
+		t.Errorf("unexpected multi-line command: %q", entries[0].Command)
+	}
+
+	if entries[1].Command != `echo C:\temp` {
+		t.Errorf("unexpected unescaped command: %q", entries[1].Command)
+	}
+}
@@ -0,0 +1,100 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Record is one line of the hook log written by `runbook-gen record`. Shell
+// hooks capture the context that history files leave out.
+type Record struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	Dir        string    `json:"cwd"`
+	ExitCode   int       `json:"exit"`
+	DurationMS int64     `json:"duration_ms"`
+	GitBranch  string    `json:"git_branch,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	Session    string    `json:"session,omitempty"`
+	Output     string    `json:"output,omitempty"` // written by wrappers that capture output; the shell hooks cannot
+}
+
+// hookLogDefaultPath returns the default hook log location.
+func hookLogDefaultPath(home string) string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "runbook-gen", "commands.jsonl")
+}
+
+// DefaultHookLogPath returns where `runbook-gen record` writes by default.
+func DefaultHookLogPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return hookLogDefaultPath(home), nil
+}
+
+// AppendRecord appends a record to the hook log, creating it (and its
+// directory) with owner-only permissions if needed.
+func AppendRecord(path string, record Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	// A single write keeps concurrent appends from interleaving
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parseHookLog reads the JSON-lines hook log. Malformed lines (e.g. a
+// partially written final line) are skipped. Commands are numbered from 1 in
+// file order.
+func parseHookLog(lines *lineReader, commandNumber int, emit emitFunc) error {
+	for lines.Scan() {
+		var record Record
+		if err := json.Unmarshal(lines.Bytes(), &record); err != nil || record.Command == "" {
+			continue
+		}
+
+		commandNumber++
+
+		entry := Entry{
+			Number:      commandNumber,
+			Timestamp:   record.Time,
+			Command:     record.Command,
+			HasTime:     !record.Time.IsZero(),
+			Duration:    time.Duration(record.DurationMS) * time.Millisecond,
+			Dir:         record.Dir,
+			ExitCode:    record.ExitCode,
+			HasExitCode: true,
+			Host:        record.Host,
+			Session:     record.Session,
+			GitBranch:   record.GitBranch,
+			Output:      record.Output,
+		}
+
+		if !emit(entry, lines.Offset()) {
+			return nil
+		}
+	}
+
+	return lines.Err()
+}
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHookLog_AppendAndExtract(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "state", "commands.jsonl")
+
+	records := []Record{
+		{Time: time.Unix(1699000000, 0), Command: "git checkout -b fix", Dir: "/srv/app", Session: "a"},
+		{Time: time.Unix(1699000010, 0), Command: "make tset", Dir: "/srv/app", ExitCode: 2, DurationMS: 1500, GitBranch: "fix", Session: "a"},
+		{Time: time.Unix(1699000020, 0), Command: "uptime", Dir: "/home/ops", Host: "bastion", Session: "b"},
+	}
+	for _, r := range records {
+		if err := AppendRecord(logPath, r); err != nil {
+			t.Fatalf("failed to append record: %v", err)
+		}
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("log not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected 0600 permissions, got %v", info.Mode().Perm())
+	}
+
+	extractor, err := NewFileExtractor(logPath, FormatHook)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	failed := entries[1]
+	if failed.Number != 2 || failed.Command != "make tset" {
+		t.Errorf("expected #2 'make tset', got #%d %q", failed.Number, failed.Command)
+	}
+	if !failed.HasExitCode || failed.ExitCode != 2 {
+		t.Errorf("expected exit code 2, got %d", failed.ExitCode)
+	}
+	if failed.Duration != 1500*time.Millisecond || failed.GitBranch != "fix" || failed.Dir != "/srv/app" {
+		t.Errorf("unexpected metadata: %+v", failed)
+	}
+	if !failed.HasTime || failed.Timestamp.Unix() != 1699000010 {
+		t.Errorf("expected timestamp 1699000010, got %d", failed.Timestamp.Unix())
+	}
+}
+
+func TestHookLog_SessionAndMalformedLines(t *testing.T) {
+	content := `{"time":"2023-11-03T08:26:40Z","command":"ls","cwd":"/","exit":0,"duration_ms":3,"session":"a"}
+not json
+{"time":"2023-11-03T08:26:50Z","command":"pwd","cwd":"/","exit":0,"duration_ms":1,"session":"b"}
+{"time":"2023-11-03T08:27:00Z","command":"whoami","cwd":"/","exit":0,"duration_ms":1,"session":"b"}
+{"time":"2023-11-03T08:27:10Z","comm`
+	extractor := createTestExtractorForFormat(t, FormatHook, content).WithSession("b")
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].Number != 1 || entries[0].Command != "pwd" || entries[1].Number != 2 {
+		t.Errorf("expected session commands renumbered from 1, got %+v", entries)
+	}
+}
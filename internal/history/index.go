@@ -0,0 +1,209 @@
+package history
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// indexStride is how many commands apart index checkpoints are.
+	indexStride = 256
+
+	// indexCacheMinSize is the smallest history file whose index is worth
+	// saving to disk; smaller files are quicker to parse than to cache.
+	indexCacheMinSize = 1 << 20
+
+	// indexTailSize is how many bytes before the indexed end are hashed to
+	// notice a history file that was rewritten rather than appended to.
+	indexTailSize = 4096
+
+	indexVersion = 1
+)
+
+// lineIndex records where every indexStride-th command starts in a history
+// file, so a range near the end can be read without parsing everything
+// before it. History files only grow between runs, so an index is brought up
+// to date by parsing from its last checkpoint onwards.
+type lineIndex struct {
+	Version     int     `json:"version"`
+	Path        string  `json:"path"`
+	Format      Format  `json:"format"`
+	Size        int64   `json:"size"`        // bytes of the file covered
+	Tail        string  `json:"tail"`        // hash of the bytes just before Size
+	Count       int     `json:"count"`       // commands in the covered bytes
+	Checkpoints []int64 `json:"checkpoints"` // Checkpoints[i] is where command i*indexStride+1 starts
+}
+
+// checkpoint returns the offset to start parsing from to reach command n, and
+// the number of commands before that offset.
+func (idx *lineIndex) checkpoint(n int) (int64, int) {
+	k := (n - 1) / indexStride
+	if k >= len(idx.Checkpoints) {
+		k = len(idx.Checkpoints) - 1
+	}
+	if k < 0 {
+		return 0, 0
+	}
+	return idx.Checkpoints[k], k * indexStride
+}
+
+// defaultIndexDir returns where indexes are cached, or "" if there is no
+// user cache directory.
+func defaultIndexDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "runbook-gen", "index")
+}
+
+// indexed reports whether the extractor can use a line index: plain history
+// files read in full. Atuin is queried directly, reader-backed history is
+// already in memory, sessions renumber commands, and transcripts can only be
+// replayed from the start.
+func (e *Extractor) indexed() bool {
+	return e.data == nil && e.filePath != "" && e.format != FormatAtuin && !e.format.transcript() && e.session == ""
+}
+
+// index returns a line index that covers the whole history file, loading it
+// from the cache and parsing only what was appended since it was built.
+func (e *Extractor) index() (*lineIndex, error) {
+	file, err := os.Open(e.filePath)
+	if err != nil {
+		return nil, ErrUnreadableFile
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, ErrUnreadableFile
+	}
+
+	idx := e.idx
+	if idx == nil {
+		idx = e.loadIndex()
+	}
+	if idx == nil || !idx.matches(file, e.filePath, e.format, info.Size()) {
+		idx = &lineIndex{Version: indexVersion, Path: e.filePath, Format: e.format}
+	}
+
+	if idx.Size == info.Size() {
+		e.idx = idx
+		return idx, nil
+	}
+
+	// Re-parse from the last checkpoint, since the command there may have
+	// been extended
+	offset, number := idx.checkpoint(idx.Count)
+	idx.Checkpoints = idx.Checkpoints[:number/indexStride]
+	idx.Count = number
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, ErrUnreadableFile
+	}
+	lines := newLineReader(file, offset)
+	err = e.format.parse(lines, number, func(entry Entry, start int64) bool {
+		if (entry.Number-1)%indexStride == 0 {
+			idx.Checkpoints = append(idx.Checkpoints, start)
+		}
+		idx.Count = entry.Number
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	idx.Size = lines.next
+	idx.Tail = tailHash(file, idx.Size)
+	e.idx = idx
+
+	if idx.Size >= indexCacheMinSize {
+		e.saveIndex(idx)
+	}
+	return idx, nil
+}
+
+// matches reports whether the index was built for this file and format, and
+// the file has only been appended to since.
+func (idx *lineIndex) matches(file *os.File, path string, format Format, size int64) bool {
+	return idx.Version == indexVersion &&
+		idx.Path == path &&
+		idx.Format == format &&
+		idx.Size <= size &&
+		idx.Tail == tailHash(file, idx.Size)
+}
+
+// tailHash hashes the indexTailSize bytes before offset end.
+func tailHash(file *os.File, end int64) string {
+	start := end - indexTailSize
+	if start < 0 {
+		start = 0
+	}
+
+	buf := make([]byte, end-start)
+	if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+		return ""
+	}
+
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// indexCachePath returns the cache file for this extractor's history file.
+func (e *Extractor) indexCachePath() string {
+	abs, err := filepath.Abs(e.filePath)
+	if err != nil {
+		abs = e.filePath
+	}
+	sum := sha256.Sum256([]byte(string(e.format) + "\x00" + abs))
+	return filepath.Join(e.indexDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadIndex reads a cached index, returning nil if there is none.
+func (e *Extractor) loadIndex() *lineIndex {
+	if e.indexDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(e.indexCachePath())
+	if err != nil {
+		return nil
+	}
+
+	var idx lineIndex
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&idx); err != nil {
+		return nil
+	}
+	return &idx
+}
+
+// saveIndex caches an index. Failures are ignored: the index is only an
+// optimisation and is rebuilt on the next run.
+func (e *Extractor) saveIndex(idx *lineIndex) {
+	if e.indexDir == "" {
+		return
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(e.indexDir, 0700); err != nil {
+		return
+	}
+
+	path := e.indexCachePath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+	}
+}
@@ -0,0 +1,148 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractor_Index_SkipsToRange(t *testing.T) {
+	extractor := createTestExtractor(t, zshHistory(1, 1000))
+
+	tests := []struct {
+		name     string
+		from, to int
+		expected []int
+	}{
+		{"last three", -3, -1, []int{998, 999, 1000}},
+		{"across a checkpoint", 256, 258, []int{256, 257, 258}},
+		{"from the top", 1, 2, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := extractor.Extract(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %d", len(tt.expected), len(entries))
+			}
+			for i, num := range tt.expected {
+				if entries[i].Number != num || entries[i].Command != fmt.Sprintf("echo %d", num) {
+					t.Errorf("entry %d: expected #%d 'echo %d', got #%d %q", i, num, num, entries[i].Number, entries[i].Command)
+				}
+			}
+		})
+	}
+
+	if got := len(extractor.idx.Checkpoints); got != 4 {
+		t.Errorf("expected 4 checkpoints, got %d", got)
+	}
+}
+
+func TestExtractor_Index_FollowsAppendsAndRewrites(t *testing.T) {
+	extractor := createTestExtractor(t, zshHistory(1, 600))
+
+	if count, _ := extractor.Count(); count != 600 {
+		t.Fatalf("expected 600 commands, got %d", count)
+	}
+
+	// Appended history is indexed incrementally
+	appendFile(t, extractor.filePath, zshHistory(601, 700))
+	entries, err := extractor.ExtractLast(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries[0].Number != 700 || entries[0].Command != "echo 700" {
+		t.Errorf("expected #700 'echo 700', got #%d %q", entries[0].Number, entries[0].Command)
+	}
+
+	// A file truncated and rewritten (e.g. by HISTSIZE) is reindexed, even
+	// though it grew
+	if err := os.WriteFile(extractor.filePath, []byte(zshHistory(2, 710)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := extractor.Count(); count != 709 {
+		t.Errorf("expected 709 commands after rewrite, got %d", count)
+	}
+}
+
+func TestExtractor_Index_Cached(t *testing.T) {
+	histFile := filepath.Join(t.TempDir(), ".zsh_history")
+	content := zshHistory(1, indexCacheMinSize/20)
+	if err := os.WriteFile(histFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	first := &Extractor{filePath: histFile, format: FormatZsh, indexDir: cacheDir}
+	want, err := first.Count()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	second := &Extractor{filePath: histFile, format: FormatZsh, indexDir: cacheDir}
+	idx := second.loadIndex()
+	if idx == nil {
+		t.Fatal("expected index to be cached")
+	}
+	if idx.Count != want {
+		t.Errorf("expected cached count %d, got %d", want, idx.Count)
+	}
+
+	entries, err := second.ExtractLast(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries[0].Number != want {
+		t.Errorf("expected #%d, got #%d", want, entries[0].Number)
+	}
+}
+
+func TestExtractor_Index_OtherFormats(t *testing.T) {
+	var bash, fish strings.Builder
+	for i := 1; i <= 300; i++ {
+		fmt.Fprintf(&bash, "#%d\necho %d\n", 1699000000+i, i)
+		fmt.Fprintf(&fish, "- cmd: echo %d\n  when: %d\n", i, 1699000000+i)
+	}
+
+	for format, content := range map[Format]string{FormatBash: bash.String(), FormatFish: fish.String()} {
+		t.Run(string(format), func(t *testing.T) {
+			extractor := createTestExtractorForFormat(t, format, content)
+
+			entries, err := extractor.Extract(-2, -1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 2 || entries[0].Command != "echo 299" || entries[1].Number != 300 {
+				t.Errorf("expected #299 and #300, got %v", entries)
+			}
+		})
+	}
+}
+
+// zshHistory returns extended history lines "echo N" for commands from..to.
+func zshHistory(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, ": %d:0;echo %d\n", 1699000000+i, i)
+	}
+	return sb.String()
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// lineReader reads lines of any length and tracks the byte offset each one
+// starts at. bufio.Scanner gives up on lines over 64KB with bufio.ErrTooLong,
+// which long pasted commands easily exceed.
+type lineReader struct {
+	r      *bufio.Reader
+	line   []byte
+	offset int64 // start of the current line
+	next   int64 // start of the line after it
+	err    error
+}
+
+// newLineReader reads lines from r, which is positioned at byte offset in
+// the underlying file.
+func newLineReader(r io.Reader, offset int64) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), next: offset}
+}
+
+// Scan advances to the next line, returning false at the end of input or on
+// error. Like bufio.Scanner, the trailing newline (and carriage return) is
+// dropped and a final line without a newline is still returned.
+func (l *lineReader) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+
+	l.line = l.line[:0]
+	l.offset = l.next
+
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		l.line = append(l.line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			l.err = err
+			if len(l.line) == 0 {
+				return false
+			}
+		}
+		break
+	}
+
+	l.next += int64(len(l.line))
+	l.line = bytes.TrimSuffix(l.line, []byte("\n"))
+	l.line = bytes.TrimSuffix(l.line, []byte("\r"))
+	return true
+}
+
+// Bytes returns the current line. It is only valid until the next Scan.
+func (l *lineReader) Bytes() []byte {
+	return l.line
+}
+
+// Text returns the current line as a string.
+func (l *lineReader) Text() string {
+	return string(l.line)
+}
+
+// Offset returns the byte offset the current line starts at.
+func (l *lineReader) Offset() int64 {
+	return l.offset
+}
+
+// Err returns the first error other than io.EOF.
+func (l *lineReader) Err() error {
+	if l.err == io.EOF {
+		return nil
+	}
+	return l.err
+}
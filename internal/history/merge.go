@@ -0,0 +1,42 @@
+package history
+
+// Merge interleaves entries from several history sources into one stream
+// ordered by timestamp. Each source must already be in history order; ties
+// go to the earlier source. An entry without a timestamp stays right after
+// the entry that preceded it in its own source.
+func Merge(sources ...[]Entry) []Entry {
+	total := 0
+	for _, entries := range sources {
+		total += len(entries)
+	}
+
+	merged := make([]Entry, 0, total)
+	next := make([]int, len(sources))
+	// Effective time of each source's head: its own timestamp, or the last
+	// one seen from that source
+	clock := make([]int64, len(sources))
+
+	for len(merged) < total {
+		best := -1
+		var bestTime int64
+		for i, entries := range sources {
+			if next[i] >= len(entries) {
+				continue
+			}
+			t := clock[i]
+			if head := entries[next[i]]; head.HasTime {
+				t = head.Timestamp.UnixNano()
+			}
+			if best < 0 || t < bestTime {
+				best, bestTime = i, t
+			}
+		}
+
+		entry := sources[best][next[best]]
+		next[best]++
+		clock[best] = bestTime
+		merged = append(merged, entry)
+	}
+
+	return merged
+}
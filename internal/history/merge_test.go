@@ -0,0 +1,41 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(1699000000+sec, 0) }
+
+	laptop := []Entry{
+		{Number: 10, Command: "ssh bastion", Timestamp: at(0), HasTime: true, Source: "laptop"},
+		{Number: 11, Command: "kubectl get pods", Timestamp: at(60), HasTime: true, Source: "laptop"},
+		{Number: 12, Command: "kubectl logs api", Timestamp: at(60), HasTime: true, Source: "laptop"},
+	}
+	bastion := []Entry{
+		{Number: 500, Command: "sudo systemctl status api", Timestamp: at(10), HasTime: true, Source: "bastion"},
+		{Number: 501, Command: "journalctl -u api", Source: "bastion"}, // no timestamp
+		{Number: 502, Command: "sudo systemctl restart api", Timestamp: at(60), HasTime: true, Source: "bastion"},
+	}
+
+	merged := Merge(laptop, bastion)
+
+	expected := []string{
+		"ssh bastion",
+		"sudo systemctl status api",
+		"journalctl -u api",
+		"kubectl get pods",
+		"kubectl logs api",
+		"sudo systemctl restart api",
+	}
+
+	if len(merged) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(merged))
+	}
+	for i, exp := range expected {
+		if merged[i].Command != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, merged[i].Command)
+		}
+	}
+}
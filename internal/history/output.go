@@ -0,0 +1,36 @@
+package history
+
+import "strings"
+
+// outputLookahead is how far ahead in a sidecar AttachOutput searches for a
+// command, so one unmatched command does not stall the rest.
+const outputLookahead = 20
+
+// AttachOutput copies captured output onto entries from a sidecar source,
+// such as a transcript recorded alongside the shell's history. Commands are
+// matched by text, in order. It returns how many entries received output.
+func AttachOutput(entries, sidecar []Entry) ([]Entry, int) {
+	attached := 0
+	next := 0
+
+	for i := range entries {
+		if entries[i].Output != "" {
+			continue
+		}
+
+		command := strings.TrimSpace(entries[i].Command)
+		for j := next; j < len(sidecar) && j < next+outputLookahead; j++ {
+			if strings.TrimSpace(sidecar[j].Command) != command {
+				continue
+			}
+			next = j + 1
+			if sidecar[j].Output != "" {
+				entries[i].Output = sidecar[j].Output
+				attached++
+			}
+			break
+		}
+	}
+
+	return entries, attached
+}
@@ -0,0 +1,31 @@
+package history
+
+import "testing"
+
+func TestAttachOutput(t *testing.T) {
+	entries := []Entry{
+		{Number: 40, Command: "kubectl get pods"},
+		{Number: 41, Command: "make deploy"},
+		{Number: 42, Command: "kubectl get pods"},
+		{Number: 43, Command: "echo not recorded"},
+	}
+	sidecar := []Entry{
+		{Command: "kubectl get pods", Output: "api-0   0/1"},
+		{Command: "ls", Output: "Makefile"},
+		{Command: "make deploy", Output: "done"},
+		{Command: "kubectl get pods", Output: "api-0   1/1"},
+	}
+
+	result, attached := AttachOutput(entries, sidecar)
+
+	if attached != 3 {
+		t.Errorf("expected 3 outputs attached, got %d", attached)
+	}
+
+	expected := []string{"api-0   0/1", "done", "api-0   1/1", ""}
+	for i, exp := range expected {
+		if result[i].Output != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, result[i].Output)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPrompts recognise common shell prompts in terminal transcripts. The
+// "cmd" group captures the command typed after the prompt; optional "host"
+// and "dir" groups record where it ran.
+var DefaultPrompts = []*regexp.Regexp{
+	// user@host:~/src$ cmd, [user@host src]$ cmd, user@host src % cmd
+	regexp.MustCompile(`^(?:\([^)]*\)\s*)?\[?[\w.-]+@(?P<host>[\w.-]+)[: ](?P<dir>[^\]$#%>]*?)\]?\s?[$#%>] (?P<cmd>.*)$`),
+	// oh-my-zsh: ➜  src git:(main) ✗ cmd
+	regexp.MustCompile(`^➜\s+(?P<dir>\S+)(?:\s+git:\([^)]*\))?(?:\s+✗)?\s+(?P<cmd>.*)$`),
+	// ~/src $ cmd, /srv/app ❯ cmd
+	regexp.MustCompile(`^(?P<dir>[~/]\S*)\s?[$%❯>] (?P<cmd>.*)$`),
+	// bash without a prompt configured: bash-5.2$ cmd
+	regexp.MustCompile(`^(?:ba|z|k)?sh-[\d.]+[$#] (?P<cmd>.*)$`),
+	// $ cmd, % cmd, ❯ cmd
+	regexp.MustCompile(`^[$%❯] (?P<cmd>.*)$`),
+}
+
+// continuationPattern matches the secondary prompt (PS2) shells print while
+// a command continues onto another line, e.g. "> " or zsh's "heredoc> ".
+var continuationPattern = regexp.MustCompile(`^\w*> (.*)$`)
+
+// scriptHeader starts every script(1) typescript.
+const scriptHeader = "Script started on "
+
+// castHeader is the first line of an asciinema recording.
+type castHeader struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Timestamp int64 `json:"timestamp"`
+	Term      *struct {
+		Cols int `json:"cols"`
+	} `json:"term"` // version 3 moved the size into "term"
+}
+
+// isCastHeader reports whether a line is an asciinema v2/v3 header.
+func isCastHeader(line string) bool {
+	var header castHeader
+	if err := json.Unmarshal([]byte(line), &header); err != nil {
+		return false
+	}
+	return header.Version >= 2 && (header.Width > 0 || header.Term != nil)
+}
+
+// parseTypescript reads a script(1) typescript: raw terminal output between
+// "Script started" and "Script done" lines. Typescripts carry no per-command
+// timing, so entries have no timestamp.
+func parseTypescript(lines *lineReader, commandNumber int, prompts []*regexp.Regexp, emit emitFunc) error {
+	p := newTranscriptParser(commandNumber, prompts, emit)
+	term := &terminal{emit: func(line string) bool {
+		if strings.HasPrefix(line, scriptHeader) || strings.HasPrefix(line, "Script done on ") {
+			return true
+		}
+		return p.line(line, time.Time{}, lines.Offset())
+	}}
+
+	for lines.Scan() {
+		if !term.write(lines.Text() + "\n") {
+			return nil
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+
+	if term.flush() {
+		p.finish()
+	}
+	return nil
+}
+
+// parseAsciicast reads an asciinema recording: a JSON header line followed
+// by [time, type, data] events. Output ("o") events are replayed through a
+// terminal; each command is timestamped when its line was completed.
+func parseAsciicast(lines *lineReader, commandNumber int, prompts []*regexp.Regexp, emit emitFunc) error {
+	if !lines.Scan() {
+		return lines.Err()
+	}
+
+	var header castHeader
+	if err := json.Unmarshal(lines.Bytes(), &header); err != nil || header.Version < 2 {
+		return fmt.Errorf("%w: not an asciinema v2 or v3 recording", ErrUnknownFormat)
+	}
+	start := time.Unix(header.Timestamp, 0)
+
+	p := newTranscriptParser(commandNumber, prompts, emit)
+	var elapsed float64
+	term := &terminal{emit: func(line string) bool {
+		at := time.Time{}
+		if header.Timestamp > 0 {
+			at = start.Add(time.Duration(elapsed * float64(time.Second)))
+		}
+		return p.line(line, at, lines.Offset())
+	}}
+
+	for lines.Scan() {
+		var event []any
+		if err := json.Unmarshal(lines.Bytes(), &event); err != nil || len(event) < 3 {
+			continue
+		}
+		t, _ := event[0].(float64)
+		kind, _ := event[1].(string)
+		data, _ := event[2].(string)
+
+		// Version 3 records the interval since the previous event
+		if header.Version >= 3 {
+			elapsed += t
+		} else {
+			elapsed = t
+		}
+
+		if kind == "o" && !term.write(data) {
+			return nil
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+
+	if term.flush() {
+		p.finish()
+	}
+	return nil
+}
+
+// transcriptParser turns the plain lines of a transcript into entries: a line
+// matching a prompt starts a command, and the lines up to the next prompt are
+// its output.
+type transcriptParser struct {
+	prompts       []*regexp.Regexp
+	commandNumber int
+	emit          emitFunc
+
+	current *Entry
+	offset  int64
+	output  []string
+	inCmd   bool // no output yet, so the command may continue onto PS2 lines
+}
+
+func newTranscriptParser(commandNumber int, prompts []*regexp.Regexp, emit emitFunc) *transcriptParser {
+	if len(prompts) == 0 {
+		prompts = DefaultPrompts
+	}
+	return &transcriptParser{prompts: prompts, commandNumber: commandNumber, emit: emit}
+}
+
+// line handles one completed terminal line. It returns false to stop parsing.
+func (p *transcriptParser) line(text string, at time.Time, offset int64) bool {
+	// PS2 lines straight after the command continue it, as typed
+	if p.inCmd {
+		if m := continuationPattern.FindStringSubmatch(text); m != nil {
+			p.current.Command += "\n" + strings.TrimRight(m[1], " \t")
+			return true
+		}
+	}
+	p.inCmd = false
+
+	for _, prompt := range p.prompts {
+		m := prompt.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+
+		if !p.flush() {
+			return false
+		}
+
+		entry := Entry{Timestamp: at, HasTime: !at.IsZero()}
+		for i, name := range prompt.SubexpNames() {
+			switch name {
+			case "cmd":
+				entry.Command = strings.TrimSpace(m[i])
+			case "host":
+				entry.Host = m[i]
+			case "dir":
+				// Only absolute directories can be cd'ed into from a runbook
+				if strings.HasPrefix(m[i], "/") {
+					entry.Dir = m[i]
+				}
+			}
+		}
+		if prompt.SubexpIndex("cmd") < 0 && len(m) > 1 {
+			entry.Command = strings.TrimSpace(m[len(m)-1])
+		}
+
+		// A bare prompt (Enter on an empty line) only ends the previous output
+		if entry.Command != "" {
+			p.current = &entry
+			p.offset = offset
+			p.inCmd = true
+		}
+		return true
+	}
+
+	if p.current != nil {
+		p.output = append(p.output, strings.TrimRight(text, " \t"))
+	}
+	return true
+}
+
+// flush emits the pending command with its output.
+func (p *transcriptParser) flush() bool {
+	if p.current == nil {
+		return true
+	}
+
+	for len(p.output) > 0 && p.output[len(p.output)-1] == "" {
+		p.output = p.output[:len(p.output)-1]
+	}
+
+	p.commandNumber++
+	entry := *p.current
+	entry.Number = p.commandNumber
+	entry.Output = strings.Join(p.output, "\n")
+
+	p.current = nil
+	p.output = nil
+	return p.emit(entry, p.offset)
+}
+
+// finish emits the last command, unless it is the "exit" that ended the
+// recording.
+func (p *transcriptParser) finish() {
+	if p.current != nil && p.current.Command == "exit" {
+		return
+	}
+	p.flush()
+}
+
+// terminal replays terminal output into plain text lines. It understands
+// just enough of a terminal to undo the redrawing shells do on the current
+// line: carriage returns, backspaces, cursor movement and line erasing.
+// Colours and other escape sequences are dropped.
+type terminal struct {
+	emit func(line string) bool
+
+	line   []rune
+	cursor int
+	state  int    // one of the termState constants
+	params []rune // parameters of the CSI sequence being read
+}
+
+const (
+	termText = iota
+	termEscape
+	termCSI
+	termOSC
+	termOSCEscape
+	termCharset
+)
+
+// write feeds output to the terminal, emitting each completed line. It
+// returns false if emit asked to stop.
+func (t *terminal) write(data string) bool {
+	for _, r := range data {
+		switch t.state {
+		case termEscape:
+			switch r {
+			case '[':
+				t.state, t.params = termCSI, t.params[:0]
+			case ']':
+				t.state = termOSC
+			case '(', ')':
+				t.state = termCharset
+			default:
+				t.state = termText
+			}
+			continue
+		case termCSI:
+			if r >= 0x40 && r <= 0x7e {
+				t.csi(r)
+				t.state = termText
+			} else {
+				t.params = append(t.params, r)
+			}
+			continue
+		case termOSC:
+			switch r {
+			case '\a':
+				t.state = termText
+			case 0x1b:
+				t.state = termOSCEscape
+			}
+			continue
+		case termOSCEscape, termCharset:
+			t.state = termText
+			continue
+		}
+
+		switch r {
+		case 0x1b:
+			t.state = termEscape
+		case '\n':
+			if !t.emit(string(t.line)) {
+				return false
+			}
+			t.line, t.cursor = t.line[:0], 0
+		case '\r':
+			t.cursor = 0
+		case '\b':
+			if t.cursor > 0 {
+				t.cursor--
+			}
+		case '\t':
+			t.put(' ')
+		default:
+			if r >= 0x20 && r != 0x7f {
+				t.put(r)
+			}
+		}
+	}
+	return true
+}
+
+// flush emits a final line that did not end in a newline. It returns false
+// if emit asked to stop.
+func (t *terminal) flush() bool {
+	if len(t.line) == 0 {
+		return true
+	}
+	line := string(t.line)
+	t.line, t.cursor = t.line[:0], 0
+	return t.emit(line)
+}
+
+// put writes a character at the cursor, overwriting what was there.
+func (t *terminal) put(r rune) {
+	for len(t.line) < t.cursor {
+		t.line = append(t.line, ' ')
+	}
+	if t.cursor < len(t.line) {
+		t.line[t.cursor] = r
+	} else {
+		t.line = append(t.line, r)
+	}
+	t.cursor++
+}
+
+// maxColumns bounds cursor movement and line length.
+const maxColumns = 4096
+
+// csi applies a control sequence that affects the current line.
+func (t *terminal) csi(final rune) {
+	params := string(t.params)
+	if strings.HasPrefix(params, "?") {
+		return // private modes such as bracketed paste
+	}
+
+	// Only the first parameter counts; recordings are untrusted, so a missing,
+	// negative or huge one must not move the cursor out of a sane line
+	n := 1
+	first, _, _ := strings.Cut(params, ";")
+	if v, err := strconv.Atoi(first); err == nil {
+		n = min(max(v, 1), maxColumns)
+	}
+
+	switch final {
+	case 'C': // cursor forward
+		t.cursor = min(t.cursor+n, maxColumns)
+	case 'D': // cursor back
+		t.cursor = max(t.cursor-n, 0)
+	case 'G': // cursor to column
+		t.cursor = max(n-1, 0)
+	case 'K': // erase in line
+		if params == "" || params == "0" {
+			if t.cursor < len(t.line) {
+				t.line = t.line[:t.cursor]
+			}
+		} else {
+			t.line = t.line[:0]
+		}
+	case 'P': // delete characters
+		if t.cursor < len(t.line) {
+			end := min(t.cursor+n, len(t.line))
+			t.line = append(t.line[:t.cursor], t.line[end:]...)
+		}
+	case '@': // insert blanks
+		if t.cursor < len(t.line) {
+			blanks := []rune(strings.Repeat(" ", n))
+			t.line = append(t.line[:t.cursor], append(blanks, t.line[t.cursor:]...)...)
+			t.line = t.line[:min(len(t.line), maxColumns)]
+		}
+	case 'J': // erase display: treat as clearing the line
+		if params == "2" || params == "3" {
+			t.line, t.cursor = t.line[:0], 0
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractor_Typescript(t *testing.T) {
+	// Recorded with script(1) around bash: bracketed paste toggles, a typo
+	// fixed with backspace, coloured output and a PS2 continuation line
+	content := "Script started on 2024-03-01 14:05:00+00:00 [COMMAND=\"bash -i\"]\n" +
+		"\x1b[?2004hops@bastion:/srv/app$ ecgo\b \b\b \b\b \bcho ready\r\n" +
+		"\x1b[?2004l\rready\r\n" +
+		"\x1b[?2004hops@bastion:/srv/app$ printf '%s\\n' \\\r\n" +
+		"\x1b[?2004l\r\x1b[?2004h> \x1b[31mred\x1b[0m\r\n" +
+		"\x1b[?2004l\r\x1b[31mred\x1b[0m\r\n" +
+		"\x1b[?2004hops@bastion:/srv/app$ \r\n" +
+		"\x1b[?2004l\r\x1b[?2004hops@bastion:/srv/app$ exit\r\n" +
+		"\x1b[?2004l\rexit\r\n" +
+		"\n" +
+		"Script done on 2024-03-01 14:06:00+00:00 [COMMAND_EXIT_CODE=\"0\"]\n"
+
+	extractor := createTestExtractorForFormat(t, FormatScript, content)
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		command, output string
+	}{
+		{"echo ready", "ready"},
+		{"printf '%s\\n' \\\nred", "red"},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d: %v", len(expected), len(entries), entries)
+	}
+
+	for i, exp := range expected {
+		if entries[i].Command != exp.command {
+			t.Errorf("entry %d: expected command %q, got %q", i, exp.command, entries[i].Command)
+		}
+		if entries[i].Output != exp.output {
+			t.Errorf("entry %d: expected output %q, got %q", i, exp.output, entries[i].Output)
+		}
+		if entries[i].Host != "bastion" || entries[i].Dir != "/srv/app" {
+			t.Errorf("entry %d: expected bastion:/srv/app, got %s:%s", i, entries[i].Host, entries[i].Dir)
+		}
+		if entries[i].HasTime {
+			t.Errorf("entry %d: expected no timestamp", i)
+		}
+	}
+}
+
+func TestExtractor_Asciicast(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"v2", `{"version": 2, "width": 80, "height": 24, "timestamp": 1699000000}
+[0.5, "o", "$ "]
+[1.0, "o", "kubectl get pods\r\n"]
+[1.2, "o", "NAME    READY\r\napi-0   1/1\r\n\u001b["]
+[1.3, "o", "1m$ \u001b[0m"]
+[4.0, "o", "make deploy\r\n"]
+[9.0, "o", "done\r\n$ "]
+`},
+		{"v3", `{"version": 3, "term": {"cols": 80, "rows": 24}, "timestamp": 1699000000}
+[0.5, "o", "$ "]
+[0.5, "o", "kubectl get pods\r\n"]
+[0.2, "i", "x"]
+[0.0, "o", "NAME    READY\r\napi-0   1/1\r\n\u001b["]
+[0.1, "o", "1m$ \u001b[0m"]
+[2.7, "o", "make deploy\r\n"]
+[5.0, "o", "done\r\n$ "]
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffFormat([]byte(tt.content)); got != FormatAsciinema {
+				t.Fatalf("expected asciinema to be sniffed, got %q", got)
+			}
+
+			extractor := createTestExtractorForFormat(t, FormatAsciinema, tt.content)
+
+			entries, err := extractor.Extract(1, 10)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != 2 {
+				t.Fatalf("expected 2 entries, got %d: %v", len(entries), entries)
+			}
+
+			if entries[0].Command != "kubectl get pods" || entries[0].Output != "NAME    READY\napi-0   1/1" {
+				t.Errorf("unexpected first entry: %q with output %q", entries[0].Command, entries[0].Output)
+			}
+			if entries[1].Command != "make deploy" || entries[1].Output != "done" {
+				t.Errorf("unexpected second entry: %q with output %q", entries[1].Command, entries[1].Output)
+			}
+
+			// Commands are timed when Enter was pressed
+			if want := time.Unix(1699000004, 0); !entries[1].Timestamp.Equal(want) {
+				t.Errorf("expected timestamp %v, got %v", want, entries[1].Timestamp)
+			}
+		})
+	}
+}
+
+func TestExtractor_WithPrompt(t *testing.T) {
+	content := "Script started on 2024-03-01 14:05:00+00:00\n" +
+		"[prod] >>> systemctl restart api\n" +
+		"[prod] >>> journalctl -u api -n 1\n" +
+		"Started api.service\n"
+
+	extractor := createTestExtractorForFormat(t, FormatScript, content)
+
+	if _, err := extractor.WithPrompt(`^\[prod\] >>> `); err == nil {
+		t.Error("expected error for prompt without a command group")
+	}
+
+	if _, err := extractor.WithPrompt(`^\[(?P<host>\w+)\] >>> (.*)$`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := extractor.Extract(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(entries) != 2 || entries[1].Command != "journalctl -u api -n 1" || entries[1].Host != "prod" {
+		t.Errorf("expected two commands on prod, got %v", entries)
+	}
+	if entries[1].Output != "Started api.service" {
+		t.Errorf("expected %q, got %q", "Started api.service", entries[1].Output)
+	}
+}
+
+func TestTerminal_LineEditing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"% \r \r$ ls\n", "$ ls"},                 // zsh PROMPT_SP
+		{"$ gti\x1b[2Dit\n", "$ git"},             // cursor back and overwrite
+		{"$ make test\x1b[4D\x1b[K\n", "$ make "}, // erase to end of line
+		{"\x1b]0;title\x07$ \x1b[1;32mok\x1b(B\x1b[m\n", "$ ok"},
+		{"$ lx\x1b[D\x1b[-5@s\n", "$ lsx"},      // negative parameter counts as 1
+		{"$ ls\x1b[-3D\x1b[;D\x1b[C\n", "$ ls"}, // as does a malformed one
+		{"$ git\x1b[3;5Dl\n", "$ lit"},          // only the first parameter counts
+	}
+
+	for _, tt := range tests {
+		var lines []string
+		term := &terminal{emit: func(line string) bool {
+			lines = append(lines, line)
+			return true
+		}}
+		term.write(tt.input)
+
+		if got := strings.Join(lines, "|"); got != tt.expected {
+			t.Errorf("terminal(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestTerminal_HugeParameters(t *testing.T) {
+	tests := []string{
+		"$ ls\x1b[999999999Cx\n",       // cursor forward
+		"$ ls\x1b[1G\x1b[999999999@\n", // insert blanks
+	}
+
+	for _, input := range tests {
+		var lines []string
+		term := &terminal{emit: func(line string) bool {
+			lines = append(lines, line)
+			return true
+		}}
+		term.write(input)
+
+		if len(lines) != 1 || len(lines[0]) > maxColumns+1 {
+			t.Errorf("terminal(%q): expected one line of at most %d columns, got %d lines", input, maxColumns+1, len(lines))
+		}
+	}
+}
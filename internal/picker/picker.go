@@ -0,0 +1,357 @@
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+var (
+	ErrCancelled = errors.New("selection cancelled")
+	ErrNoTTY     = errors.New("interactive picking needs a terminal")
+)
+
+// Key is a keypress decoded from raw terminal input.
+type Key int
+
+const (
+	KeyNone Key = iota
+	KeyUp
+	KeyDown
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyMarkStart
+	KeyMarkEnd
+	KeyToggle
+	KeyClear
+	KeyConfirm
+	KeyCancel
+)
+
+const helpLine = "↑/↓ move  s start  e end  space toggle  c clear  enter generate  q quit"
+
+// Picker holds the state of an interactive range selection: a cursor, an
+// optional start and end mark, and commands toggled in or out individually.
+// Marks and toggles are indexes into entries.
+type Picker struct {
+	entries []history.Entry
+	cursor  int
+	offset  int // first visible row
+	start   int // -1 when unset
+	end     int // -1 when unset
+	toggled map[int]bool
+	status  string
+}
+
+// New creates a picker over entries with the cursor on the most recent one.
+func New(entries []history.Entry) *Picker {
+	return &Picker{
+		entries: entries,
+		cursor:  len(entries) - 1,
+		start:   -1,
+		end:     -1,
+		toggled: make(map[int]bool),
+	}
+}
+
+// inRange reports whether index i lies between the start and end marks. With
+// only a start mark the range runs to the most recent command.
+func (p *Picker) inRange(i int) bool {
+	if p.start < 0 {
+		return false
+	}
+	end := p.end
+	if end < 0 {
+		end = len(p.entries) - 1
+	}
+	lo, hi := p.start, end
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return i >= lo && i <= hi
+}
+
+// isSelected reports whether the entry at index i will go into the runbook.
+// Toggling flips an entry relative to the marked range.
+func (p *Picker) isSelected(i int) bool {
+	return p.inRange(i) != p.toggled[i]
+}
+
+// Selected returns the chosen entries in history order.
+func (p *Picker) Selected() []history.Entry {
+	var result []history.Entry
+	for i, entry := range p.entries {
+		if p.isSelected(i) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Handle applies a keypress. It returns true once the selection is confirmed,
+// and ErrCancelled if the user quit.
+func (p *Picker) Handle(key Key, pageSize int) (bool, error) {
+	p.status = ""
+
+	switch key {
+	case KeyUp:
+		p.move(-1)
+	case KeyDown:
+		p.move(1)
+	case KeyPageUp:
+		p.move(-pageSize)
+	case KeyPageDown:
+		p.move(pageSize)
+	case KeyHome:
+		p.move(-len(p.entries))
+	case KeyEnd:
+		p.move(len(p.entries))
+	case KeyMarkStart:
+		p.start = p.cursor
+	case KeyMarkEnd:
+		p.end = p.cursor
+		if p.start < 0 {
+			p.start = p.cursor
+		}
+	case KeyToggle:
+		p.toggled[p.cursor] = !p.toggled[p.cursor]
+	case KeyClear:
+		p.start, p.end = -1, -1
+		p.toggled = make(map[int]bool)
+	case KeyConfirm:
+		if len(p.Selected()) == 0 {
+			p.status = "Nothing selected: press s and e to mark a range, or space to pick commands"
+			return false, nil
+		}
+		return true, nil
+	case KeyCancel:
+		return false, ErrCancelled
+	}
+	return false, nil
+}
+
+// move shifts the cursor by delta rows, clamped to the entry list.
+func (p *Picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+	if p.cursor >= len(p.entries) {
+		p.cursor = len(p.entries) - 1
+	}
+}
+
+// Render draws the visible rows, keeping the cursor on screen, followed by a
+// help and status line. Lines end in "\r\n" because the terminal is in raw
+// mode.
+func (p *Picker) Render(w io.Writer, width, height int) {
+	rows := height - 2
+	if rows < 1 {
+		rows = 1
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+
+	for i := p.offset; i < len(p.entries) && i < p.offset+rows; i++ {
+		sb.WriteString(truncate(p.row(i), width))
+		sb.WriteString("\r\n")
+	}
+
+	sb.WriteString(truncate(helpLine, width))
+	sb.WriteString("\r\n")
+	status := p.status
+	if status == "" {
+		status = fmt.Sprintf("%d of %d commands selected", len(p.Selected()), len(p.entries))
+	}
+	sb.WriteString(truncate(status, width))
+
+	_, _ = io.WriteString(w, sb.String())
+}
+
+// row formats one entry: cursor, selection box, range marker, command number,
+// timestamp and the first line of the command.
+func (p *Picker) row(i int) string {
+	entry := p.entries[i]
+
+	cursor := "  "
+	if i == p.cursor {
+		cursor = "> "
+	}
+
+	box := "[ ]"
+	if p.isSelected(i) {
+		box = "[x]"
+	}
+
+	mark := " "
+	switch {
+	case i == p.start && i == p.end:
+		mark = "="
+	case i == p.start:
+		mark = "S"
+	case i == p.end:
+		mark = "E"
+	}
+
+	ts := "                "
+	if entry.HasTime {
+		ts = entry.Timestamp.Format("2006-01-02 15:04")
+	}
+
+	command, _, multiline := strings.Cut(entry.Command, "\n")
+	if multiline {
+		command += " ↵…"
+	}
+
+	return fmt.Sprintf("%s%s %s %6d  %s  %s", cursor, box, mark, entry.Number, ts, command)
+}
+
+// truncate shortens s to at most width runes.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// ReadKey decodes the next keypress, including arrow and paging escape
+// sequences. Unrecognised input returns KeyNone.
+func ReadKey(r *bufio.Reader) (Key, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return KeyNone, err
+	}
+
+	switch b {
+	case 'k':
+		return KeyUp, nil
+	case 'j':
+		return KeyDown, nil
+	case 'g':
+		return KeyHome, nil
+	case 'G':
+		return KeyEnd, nil
+	case 's':
+		return KeyMarkStart, nil
+	case 'e':
+		return KeyMarkEnd, nil
+	case ' ', 'x':
+		return KeyToggle, nil
+	case 'c':
+		return KeyClear, nil
+	case '\r', '\n':
+		return KeyConfirm, nil
+	case 'q', 0x03: // Ctrl-C
+		return KeyCancel, nil
+	case 0x1b:
+		// A lone Escape arrives without a sequence behind it
+		if r.Buffered() == 0 {
+			return KeyCancel, nil
+		}
+		return readEscape(r)
+	}
+	return KeyNone, nil
+}
+
+// readEscape decodes the remainder of a CSI or SS3 escape sequence.
+func readEscape(r *bufio.Reader) (Key, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return KeyNone, err
+	}
+	if b != '[' && b != 'O' {
+		return KeyNone, nil
+	}
+
+	var seq []byte
+	for {
+		b, err = r.ReadByte()
+		if err != nil {
+			return KeyNone, err
+		}
+		seq = append(seq, b)
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		return KeyUp, nil
+	case "B":
+		return KeyDown, nil
+	case "H", "1~":
+		return KeyHome, nil
+	case "F", "4~":
+		return KeyEnd, nil
+	case "5~":
+		return KeyPageUp, nil
+	case "6~":
+		return KeyPageDown, nil
+	}
+	return KeyNone, nil
+}
+
+// Run shows the picker on tty until the user confirms or cancels, and returns
+// the selected entries. The terminal is put into raw mode on the alternate
+// screen and restored before returning.
+func Run(tty *os.File, entries []history.Entry) ([]history.Entry, error) {
+	fd := int(tty.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, ErrNoTTY
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	// Alternate screen, hidden cursor; undone on the way out
+	_, _ = io.WriteString(tty, "\x1b[?1049h\x1b[?25l")
+	defer func() { _, _ = io.WriteString(tty, "\x1b[?25h\x1b[?1049l") }()
+
+	p := New(entries)
+	in := bufio.NewReader(tty)
+
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		p.Render(tty, width, height)
+
+		key, err := ReadKey(in)
+		if err != nil {
+			return nil, err
+		}
+
+		done, err := p.Handle(key, height-2)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return p.Selected(), nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package picker
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func testEntries() []history.Entry {
+	commands := []string{"ls", "git pull", "make build", "make test", "git push"}
+	entries := make([]history.Entry, len(commands))
+	for i, cmd := range commands {
+		entries[i] = history.Entry{
+			Number:    100 + i,
+			Command:   cmd,
+			Timestamp: time.Unix(1699000000+int64(i)*60, 0),
+			HasTime:   true,
+		}
+	}
+	return entries
+}
+
+func TestPicker_MarkRangeAndToggle(t *testing.T) {
+	p := New(testEntries())
+
+	// Cursor starts on the most recent command
+	keys := []Key{KeyUp, KeyUp, KeyUp, KeyMarkStart, KeyDown, KeyDown, KeyMarkEnd, KeyUp, KeyToggle}
+	for _, key := range keys {
+		if _, err := p.Handle(key, 10); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	done, err := p.Handle(KeyConfirm, 10)
+	if err != nil || !done {
+		t.Fatalf("expected confirmed selection, got done=%v err=%v", done, err)
+	}
+
+	expected := []string{"git pull", "make test"}
+	selected := p.Selected()
+	if len(selected) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(selected))
+	}
+	for i, exp := range expected {
+		if selected[i].Command != exp {
+			t.Errorf("entry %d: expected %q, got %q", i, exp, selected[i].Command)
+		}
+	}
+}
+
+func TestPicker_StartOnlyRunsToLatest(t *testing.T) {
+	p := New(testEntries())
+
+	for _, key := range []Key{KeyHome, KeyDown, KeyDown, KeyDown, KeyMarkStart, KeyHome, KeyToggle} {
+		_, _ = p.Handle(key, 10)
+	}
+
+	selected := p.Selected()
+	if len(selected) != 3 || selected[0].Command != "ls" || selected[1].Number != 103 || selected[2].Number != 104 {
+		t.Errorf("expected #100, #103 and #104, got %v", selected)
+	}
+}
+
+func TestPicker_ConfirmRequiresSelection(t *testing.T) {
+	p := New(testEntries())
+
+	done, err := p.Handle(KeyConfirm, 10)
+	if err != nil || done {
+		t.Errorf("expected confirm to be refused, got done=%v err=%v", done, err)
+	}
+
+	if _, err := p.Handle(KeyCancel, 10); err != ErrCancelled {
+		t.Errorf("expected ErrCancelled, got %v", err)
+	}
+}
+
+func TestPicker_RenderKeepsCursorVisible(t *testing.T) {
+	p := New(testEntries())
+
+	var sb strings.Builder
+	p.Render(&sb, 80, 4) // two rows of entries plus help and status
+
+	out := sb.String()
+	if strings.Contains(out, "git pull") {
+		t.Errorf("expected early entries scrolled off, got %q", out)
+	}
+	if !strings.Contains(out, "> [ ]      104  ") {
+		t.Errorf("expected cursor on #104, got %q", out)
+	}
+	if !strings.Contains(out, "0 of 5 commands selected") {
+		t.Errorf("expected selection count in status line, got %q", out)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Key
+	}{
+		{"\x1b[A\x1b[B", []Key{KeyUp, KeyDown}},
+		{"\x1bOA", []Key{KeyUp}},
+		{"\x1b[5~\x1b[6~", []Key{KeyPageUp, KeyPageDown}},
+		{"se x\r", []Key{KeyMarkStart, KeyMarkEnd, KeyToggle, KeyToggle, KeyConfirm}},
+		{"z\x03", []Key{KeyNone, KeyCancel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.input))
+			for i, exp := range tt.expected {
+				key, err := ReadKey(r)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if key != exp {
+					t.Errorf("key %d: expected %d, got %d", i, exp, key)
+				}
+			}
+		})
+	}
+}
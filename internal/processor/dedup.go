@@ -9,17 +9,38 @@ import (
 
 // Dedup removes redundant commands while preserving meaningful repetition.
 type Dedup struct {
-	TimeGap time.Duration // Gap to consider commands intentionally repeated
+	TimeGap    time.Duration // Gap to consider commands intentionally repeated
+	DropFailed bool          // Drop commands recorded with a non-zero exit code
 }
 
 // NewDedup creates a new deduplicator with default settings.
 func NewDedup() *Dedup {
 	return &Dedup{
-		TimeGap: 30 * time.Second,
+		TimeGap:    30 * time.Second,
+		DropFailed: true,
 	}
 }
 
+// DropFailedCommands removes commands whose source recorded a non-zero exit
+// code. Entries without exit status information are kept, as are negative
+// codes, which sources such as atuin use for an unknown status.
+func (d *Dedup) DropFailedCommands(entries []history.Entry) []history.Entry {
+	if !d.DropFailed {
+		return entries
+	}
+
+	var result []history.Entry
+	for _, entry := range entries {
+		if entry.HasExitCode && entry.ExitCode > 0 {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
 // Process removes duplicate and redundant commands from the entry list.
+// Failed commands are dropped separately, with DropFailedCommands.
 func (d *Dedup) Process(entries []history.Entry) []history.Entry {
 	if len(entries) == 0 {
 		return entries
@@ -41,6 +62,12 @@ func (d *Dedup) Process(entries []history.Entry) []history.Entry {
 
 		prev := result[len(result)-1]
 
+		// Commands from different machines are never merged
+		if prev.Source != entry.Source {
+			result = append(result, entry)
+			continue
+		}
+
 		// Check if this is an exact duplicate
 		if d.isExactDuplicate(prev, entry) {
 			// If there's a significant time gap, it's intentional repetition
@@ -66,7 +93,7 @@ func (d *Dedup) Process(entries []history.Entry) []history.Entry {
 		}
 
 		// Look ahead to see if this command is followed by a corrected version
-		if i+1 < len(entries) && d.isTypoCorrection(entry.Command, entries[i+1].Command) {
+		if i+1 < len(entries) && entries[i+1].Source == entry.Source && d.isTypoCorrection(entry.Command, entries[i+1].Command) {
 			// Skip this one, we'll use the next one
 			continue
 		}
@@ -206,3 +206,53 @@ func TestLevenshteinDistance(t *testing.T) {
 		})
 	}
 }
+
+func TestDedup_DropsFailedCommands(t *testing.T) {
+	dedup := NewDedup()
+
+	entries := []history.Entry{
+		{Number: 1, Command: "make tset", ExitCode: 2, HasExitCode: true},
+		{Number: 2, Command: "kubectl apply -f deploy.yaml", ExitCode: 0, HasExitCode: true},
+		{Number: 3, Command: "git push"},
+		{Number: 4, Command: "tail -f app.log", ExitCode: -1, HasExitCode: true},
+	}
+
+	result := dedup.DropFailedCommands(entries)
+
+	if len(result) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(result))
+	}
+
+	if result[0].Number != 2 || result[1].Number != 3 || result[2].Number != 4 {
+		t.Errorf("expected entries #2 to #4, got #%d, #%d and #%d", result[0].Number, result[1].Number, result[2].Number)
+	}
+
+	dedup.DropFailed = false
+	if result := dedup.DropFailedCommands(entries); len(result) != 4 {
+		t.Errorf("expected failed command kept when DropFailed=false, got %d entries", len(result))
+	}
+
+	if result := NewDedup().Process(entries); len(result) != 4 {
+		t.Errorf("expected Process to leave failed commands to DropFailedCommands, got %d entries", len(result))
+	}
+}
+
+func TestDedup_KeepsRepeatsOnOtherMachines(t *testing.T) {
+	dedup := NewDedup()
+
+	entries := []history.Entry{
+		{Number: 1, Command: "systemctl restart api", Source: "web-1"},
+		{Number: 7, Command: "systemctl restart api", Source: "web-2"},
+		{Number: 8, Command: "systemctl restart api", Source: "web-2"},
+	}
+
+	result := dedup.Process(entries)
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(result))
+	}
+
+	if result[0].Source != "web-1" || result[1].Source != "web-2" {
+		t.Errorf("expected one restart per machine, got %v", result)
+	}
+}
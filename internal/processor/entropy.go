@@ -0,0 +1,229 @@
+package processor
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// EntropyPatternName is the PatternName recorded for values redacted by the
+// entropy detector rather than by one of the regex patterns.
+const EntropyPatternName = "high-entropy-string"
+
+// EntropyConfig tunes the entropy detector, which catches secrets that no
+// pattern knows about, such as custom internal tokens. A token is flagged
+// when it is at least MinLength characters long, looks like base64 or hex,
+// and its Shannon entropy reaches the threshold for its alphabet.
+type EntropyConfig struct {
+	MinLength     int     // Shortest token considered; 0 disables the detector
+	Base64Entropy float64 // Bits per character for base64-like tokens
+	HexEntropy    float64 // Bits per character for hex tokens
+}
+
+// DefaultEntropyConfig returns the thresholds used unless configured
+// otherwise. Random base64 reaches 4 bits per character from about 20
+// characters on, and random hex about 3.
+func DefaultEntropyConfig() EntropyConfig {
+	return EntropyConfig{
+		MinLength:     20,
+		Base64Entropy: 4.0,
+		HexEntropy:    3.0,
+	}
+}
+
+// entropyToken matches the candidate tokens of a command: runs of base64 and
+// URL-safe base64 characters with optional padding. Anything else, such as
+// spaces, quotes, "=" and ":", separates tokens.
+var entropyToken = regexp.MustCompile(`[A-Za-z0-9+/_-]+={0,2}`)
+
+// digestPrefixes introduce content digests, e.g. nginx@sha256:<hex>.
+var digestPrefixes = []string{"sha1:", "sha256:", "sha384:", "sha512:"}
+
+// commitContexts precede commit SHAs in forge URLs.
+var commitContexts = []string{"/commit/", "/commits/", "/tree/", "/blob/"}
+
+// nameWord matches the words of a camel-case name: "AddOAuth2Support" is
+// Add, OAuth, 2 and Support.
+var nameWord = regexp.MustCompile(`[A-Z]+[a-z]*|[a-z]+|[0-9]+`)
+
+// redactHighEntropy replaces high-entropy tokens in text with <REDACTED>.
+// Commit SHAs are kept when gitCommand is set, i.e. text belongs to a git
+// command, or when they follow ":", "@" or "=" as image tags and values do,
+// as are image digests and allowlisted values. It reports whether
+// anything was redacted.
+func (s *Sanitizer) redactHighEntropy(text string, gitCommand bool) (string, bool) {
+	if s.entropy.MinLength <= 0 {
+		return text, false
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range entropyToken.FindAllStringIndex(text, -1) {
+		for _, span := range s.entropy.secretSpans(text[loc[0]:loc[1]]) {
+			start, end := loc[0]+span[0], loc[0]+span[1]
+			token := text[start:end]
+			if s.allowlisted(token) || isDigest(text[:start], token, gitCommand) {
+				continue
+			}
+			sb.WriteString(text[last:start])
+			sb.WriteString(marker(s.variables.name(token, EntropyPatternName, EntropyPatternName)))
+			last = end
+		}
+	}
+	if last == 0 {
+		return text, false
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), true
+}
+
+// secretSpans returns the spans of a token that look like random secrets.
+// Paths, branches and resource names are split at "/" and each segment is
+// judged on its own, so a camel-case directory or branch name does not make
+// the whole path look random; base64, recognised by "+" or "=" padding, is
+// judged whole.
+func (c EntropyConfig) secretSpans(token string) [][2]int {
+	if !strings.Contains(token, "/") || strings.ContainsAny(token, "+=") {
+		if c.isSecret(token) {
+			return [][2]int{{0, len(token)}}
+		}
+		return nil
+	}
+
+	var spans [][2]int
+	start := 0
+	for _, segment := range strings.Split(token, "/") {
+		if c.isSecret(segment) && !isName(segment) {
+			spans = append(spans, [2]int{start, start + len(segment)})
+		}
+		start += len(segment) + 1
+	}
+	return spans
+}
+
+// isSecret reports whether a token looks like a random secret.
+func (c EntropyConfig) isSecret(token string) bool {
+	value := strings.TrimRight(token, "=")
+	if len(value) < c.MinLength {
+		return false
+	}
+
+	if isHex(value) {
+		return hasDigit(value) && hasLetter(value) && shannonEntropy(value) >= c.HexEntropy
+	}
+
+	// Base64 mixes cases and digits; paths, flags and hyphenated names made of
+	// plain words do not
+	if !hasUpper(value) || !hasLower(value) || !hasDigit(value) || isWords(value) {
+		return false
+	}
+	return shannonEntropy(value) >= c.Base64Entropy
+}
+
+// isDigest reports whether a hex token is a content digest or commit SHA,
+// judging by the text before it.
+func isDigest(before, token string, gitCommand bool) bool {
+	if !isHex(token) {
+		return false
+	}
+
+	for _, prefix := range digestPrefixes {
+		if strings.HasSuffix(before, prefix) {
+			return true
+		}
+	}
+
+	// Full SHA-1 or SHA-256 object names
+	if len(token) != 40 && len(token) != 64 {
+		return false
+	}
+	if gitCommand || strings.HasSuffix(before, ":") || strings.HasSuffix(before, "@") || strings.HasSuffix(before, "=") {
+		return true
+	}
+	for _, context := range commitContexts {
+		if strings.HasSuffix(before, context) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitCommand reports whether a command runs git, whose arguments and
+// output are full of commit SHAs.
+func isGitCommand(command string) bool {
+	tool := extractTool(command)
+	return tool == "git" || tool == "gh"
+}
+
+// isWords reports whether every segment of a "/", "-" or "_" separated token
+// is all letters or all digits, like "Project-Staging-2024".
+func isWords(token string) bool {
+	segments := strings.FieldsFunc(token, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '+'
+	})
+	for _, segment := range segments {
+		if hasLetter(segment) && hasDigit(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// isName reports whether a path segment reads as a name, like
+// "JIRA1234-AddOAuth2Support": the words of each "-" or "_" separated part
+// average at least 3.5 characters, where random base64 changes case or
+// switches to a digit every one or two characters.
+func isName(segment string) bool {
+	for _, part := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' }) {
+		words := nameWord.FindAllString(part, -1)
+		if len(words) == 0 || 2*len(part) < 7*len(words) {
+			return false
+		}
+	}
+	return true
+}
+
+// shannonEntropy returns the entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	n := float64(len(s))
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func hasDigit(s string) bool {
+	return strings.ContainsAny(s, "0123456789")
+}
+
+func hasLetter(s string) bool {
+	return hasUpper(s) || hasLower(s)
+}
+
+func hasUpper(s string) bool {
+	return strings.ContainsAny(s, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+func hasLower(s string) bool {
+	return strings.ContainsAny(s, "abcdefghijklmnopqrstuvwxyz")
+}
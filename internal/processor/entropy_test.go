@@ -0,0 +1,217 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"", 0},
+		{"aaaa", 0},
+		{"abab", 1},
+		{"abcd", 2},
+		{"0123456789abcdef", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := shannonEntropy(tt.input); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSanitizer_HighEntropy(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "custom base64 token",
+			input:    "deployctl login --auth xK9mP2vL8qR4tN7wZ3bY6cH1",
+			expected: `deployctl login --auth "${HIGH_ENTROPY_STRING}"`,
+		},
+		{
+			name:     "base64 with padding and slashes",
+			input:    "curl -H 'X-Internal: dGhpc0lzQVNlY3JldDEyMw/9kLm+Qp==' https://internal",
+			expected: `curl -H 'X-Internal: '"${HIGH_ENTROPY_STRING}" https://internal`,
+		},
+		{
+			name:     "hex token",
+			input:    "vendor-cli configure 9f86d081884c7d659a2feaa0c55ad015",
+			expected: `vendor-cli configure "${HIGH_ENTROPY_STRING}"`,
+		},
+		{
+			name:     "40 character hex outside git",
+			input:    "vendor-cli configure 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+			expected: `vendor-cli configure "${HIGH_ENTROPY_STRING}"`,
+		},
+		{
+			name:     "git SHA",
+			input:    "git checkout 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+			expected: "git checkout 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+		},
+		{
+			name:     "commit URL",
+			input:    "curl https://github.com/org/repo/commit/9f86d081884c7d659a2feaa0c55ad015a3bf4f1b.patch",
+			expected: "curl https://github.com/org/repo/commit/9f86d081884c7d659a2feaa0c55ad015a3bf4f1b.patch",
+		},
+		{
+			name:     "image digest",
+			input:    "docker pull nginx@sha256:2cf4dd1f4c4e4a8bd2b8c1e0a5f3d7e6b9a8c7d6e5f4a3b2c1d0e9f8a7b6c5d4",
+			expected: "docker pull nginx@sha256:2cf4dd1f4c4e4a8bd2b8c1e0a5f3d7e6b9a8c7d6e5f4a3b2c1d0e9f8a7b6c5d4",
+		},
+		{
+			name:     "pod name",
+			input:    "kubectl logs nginx-deployment-7d4b9c8f6-x2kq9",
+			expected: "kubectl logs nginx-deployment-7d4b9c8f6-x2kq9",
+		},
+		{
+			name:     "hyphenated words",
+			input:    "aws s3 cp backup.tar s3://ProjectName-Staging-2024-Backup/",
+			expected: "aws s3 cp backup.tar s3://ProjectName-Staging-2024-Backup/",
+		},
+		{
+			name:     "camel case identifier",
+			input:    "java -cp app.jar com.example.HelloWorldApplication2",
+			expected: "java -cp app.jar com.example.HelloWorldApplication2",
+		},
+		{
+			name:     "path with camel-case names",
+			input:    "cd ~/src/MyProject2024/BackendServiceV2",
+			expected: "cd ~/src/MyProject2024/BackendServiceV2",
+		},
+		{
+			name:     "branch",
+			input:    "git checkout feature/JIRA1234-AddOAuth2Support && echo feature/JIRA1234-AddOAuth2Support",
+			expected: "git checkout feature/JIRA1234-AddOAuth2Support && echo feature/JIRA1234-AddOAuth2Support",
+		},
+		{
+			name:     "kubernetes resource",
+			input:    "kubectl rollout restart deployment/Api2Gateway3Service",
+			expected: "kubectl rollout restart deployment/Api2Gateway3Service",
+		},
+		{
+			name:     "S3 object key",
+			input:    "aws s3 cp s3://DataLake2024/Exports/CustomerReport2024Q3/Part0001Final.csv .",
+			expected: "aws s3 cp s3://DataLake2024/Exports/CustomerReport2024Q3/Part0001Final.csv .",
+		},
+		{
+			name:     "token in a path",
+			input:    "curl https://hooks.internal/in/xK9mP2vL8qR4tN7wZ3bY6cH1",
+			expected: `curl https://hooks.internal/in/"${HIGH_ENTROPY_STRING}"`,
+		},
+		{
+			name:     "image tag SHA",
+			input:    "docker run app:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+			expected: "docker run app:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+		},
+		{
+			name:     "helm value SHA",
+			input:    "helm upgrade api ./chart --set image.tag=9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+			expected: "helm upgrade api ./chart --set image.tag=9f86d081884c7d659a2feaa0c55ad015a3bf4f1b",
+		},
+		{
+			name:     "kubectl image SHA",
+			input:    "kubectl set image deployment/api api=registry.example.com/api:2cf4dd1f4c4e4a8bd2b8c1e0a5f3d7e6b9a8c7d6e5f4a3b2c1d0e9f8a7b6c5d4",
+			expected: "kubectl set image deployment/api api=registry.example.com/api:2cf4dd1f4c4e4a8bd2b8c1e0a5f3d7e6b9a8c7d6e5f4a3b2c1d0e9f8a7b6c5d4",
+		},
+		{
+			name:     "short token",
+			input:    "deployctl login --auth xK9mP2vL8qR4",
+			expected: "deployctl login --auth xK9mP2vL8qR4",
+		},
+	}
+
+	sanitizer := NewSanitizer()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := sanitizer.SanitizeString(tt.input); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizer_HighEntropyRedaction(t *testing.T) {
+	entries := []history.Entry{
+		{Number: 7, Command: "deployctl login --auth xK9mP2vL8qR4tN7wZ3bY6cH1"},
+		{Number: 8, Command: "deployctl whoami", Output: "session: Qm7Rt2Yp9Wx4Zk8Lv3Nb6Hc1"},
+	}
+
+	result, redactions := NewSanitizer().WithStrictMode(true).Process(entries)
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(result))
+	}
+	if result[1].Output != "session: ${HIGH_ENTROPY_STRING_2}" {
+		t.Errorf("expected output redacted, got %q", result[1].Output)
+	}
+
+	if len(redactions) != 2 {
+		t.Fatalf("expected 2 redactions, got %d", len(redactions))
+	}
+	for i, r := range redactions {
+		if r.PatternName != EntropyPatternName {
+			t.Errorf("expected pattern %q, got %q", EntropyPatternName, r.PatternName)
+		}
+		if r.EntryNumber != entries[i].Number {
+			t.Errorf("expected entry %d, got %d", entries[i].Number, r.EntryNumber)
+		}
+	}
+	if redactions[0].Original != entries[0].Command {
+		t.Errorf("expected original %q, got %q", entries[0].Command, redactions[0].Original)
+	}
+}
+
+func TestSanitizer_EntropyConfig(t *testing.T) {
+	input := "deployctl login --auth xK9mP2vL8qR4tN7wZ3bY6cH1"
+
+	tests := []struct {
+		name      string
+		sanitizer *Sanitizer
+		expected  string
+	}{
+		{
+			name:      "disabled",
+			sanitizer: NewSanitizer().WithEntropy(EntropyConfig{}),
+			expected:  input,
+		},
+		{
+			name: "longer minimum length",
+			sanitizer: NewSanitizer().WithEntropy(EntropyConfig{
+				MinLength:     32,
+				Base64Entropy: 4.0,
+				HexEntropy:    3.0,
+			}),
+			expected: input,
+		},
+		{
+			name:      "allowlisted",
+			sanitizer: NewSanitizer().WithAllowlist([]string{"xK9mP2vL8qR4tN7wZ3bY6cH1"}),
+			expected:  input,
+		},
+		{
+			name:      "default",
+			sanitizer: NewSanitizer(),
+			expected:  `deployctl login --auth "${HIGH_ENTROPY_STRING}"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.sanitizer.SanitizeString(input); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
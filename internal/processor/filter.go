@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+// Filter keeps or drops commands that match a regex or use one of a set of
+// tools.
+type Filter struct {
+	Name    string // Describes the filter in reports, e.g. `--exclude "^ls"`
+	Exclude bool   // Drop matching commands instead of keeping them
+	match   func(command string) bool
+}
+
+// NewPatternFilter creates a filter matching commands against any of a set
+// of regexes, the way grep -e does. The whole command is matched, including
+// continuation lines.
+func NewPatternFilter(patterns []string, exclude bool) (*Filter, error) {
+	var regexes []*regexp.Regexp
+	var quoted []string
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		regexes = append(regexes, re)
+		quoted = append(quoted, fmt.Sprintf("%q", pattern))
+	}
+
+	return &Filter{
+		Name:    fmt.Sprintf("%s %s", filterFlag("include", "exclude", exclude), strings.Join(quoted, ",")),
+		Exclude: exclude,
+		match: func(command string) bool {
+			return slices.ContainsFunc(regexes, func(re *regexp.Regexp) bool { return re.MatchString(command) })
+		},
+	}, nil
+}
+
+// NewToolFilter creates a filter matching commands whose tool is one of
+// tools. The tool is the command's first word, looking past sudo, common
+// wrappers, environment assignments and directories.
+func NewToolFilter(tools []string, exclude bool) *Filter {
+	set := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		set[tool] = true
+	}
+
+	return &Filter{
+		Name:    fmt.Sprintf("%s %s", filterFlag("tool", "skip-tool", exclude), strings.Join(tools, ",")),
+		Exclude: exclude,
+		match: func(command string) bool {
+			return set[commandTool(command)]
+		},
+	}
+}
+
+// Apply returns the entries the filter keeps.
+func (f *Filter) Apply(entries []history.Entry) []history.Entry {
+	var result []history.Entry
+	for _, entry := range entries {
+		if f.match(entry.Command) != f.Exclude {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// filterFlag names the CLI flag a filter came from, for reports.
+func filterFlag(include, exclude string, isExclude bool) string {
+	if isExclude {
+		return "--" + exclude
+	}
+	return "--" + include
+}
+
+// commandTool returns the tool a command runs, as a bare name:
+// "FOO=1 sudo /usr/local/bin/helm upgrade" uses helm.
+func commandTool(command string) string {
+	fields := strings.Fields(command)
+	for len(fields) > 1 && strings.Contains(fields[0], "=") {
+		fields = fields[1:]
+	}
+	return filepath.Base(extractTool(strings.Join(fields, " ")))
+}
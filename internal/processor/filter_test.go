@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestFilter_Apply(t *testing.T) {
+	entries := []history.Entry{
+		{Number: 1, Command: "ls -la"},
+		{Number: 2, Command: "kubectl get pods"},
+		{Number: 3, Command: "sudo helm upgrade api ./chart"},
+		{Number: 4, Command: "KUBECONFIG=prod.yaml /usr/local/bin/kubectl rollout status deploy/api"},
+		{Number: 5, Command: "vim values.yaml"},
+		{Number: 6, Command: "clear"},
+	}
+
+	mustPattern := func(exclude bool, patterns ...string) *Filter {
+		f, err := NewPatternFilter(patterns, exclude)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return f
+	}
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		expected []int
+	}{
+		{"tool include", NewToolFilter([]string{"kubectl", "helm"}, false), []int{2, 3, 4}},
+		{"tool exclude", NewToolFilter([]string{"ls", "clear", "vim"}, true), []int{2, 3, 4}},
+		{"pattern include", mustPattern(false, `rollout|upgrade`), []int{3, 4}},
+		{"pattern exclude", mustPattern(true, `^(ls|clear)\b`), []int{2, 3, 4, 5}},
+		{"any of several includes", mustPattern(false, `^ls`, `helm`, `^vim`), []int{1, 3, 5}},
+		{"any of several excludes", mustPattern(true, `^ls`, `^clear$`, `kubectl`), []int{3, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.filter.Apply(entries)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %d", len(tt.expected), len(result))
+			}
+			for i, num := range tt.expected {
+				if result[i].Number != num {
+					t.Errorf("entry %d: expected number %d, got %d", i, num, result[i].Number)
+				}
+			}
+		})
+	}
+}
+
+func TestNewPatternFilter_Invalid(t *testing.T) {
+	if _, err := NewPatternFilter([]string{`^ls`, `kubectl(`}, false); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestFilter_Name(t *testing.T) {
+	f, _ := NewPatternFilter([]string{`^ls`, `^git status`}, true)
+	if f.Name != `--exclude "^ls","^git status"` {
+		t.Errorf("expected %q, got %q", `--exclude "^ls","^git status"`, f.Name)
+	}
+
+	tool := NewToolFilter([]string{"kubectl", "helm"}, false)
+	if tool.Name != "--tool kubectl,helm" {
+		t.Errorf("expected %q, got %q", "--tool kubectl,helm", tool.Name)
+	}
+}
@@ -20,6 +20,7 @@ type CommandGroup struct {
 	Description string
 	Commands    []history.Entry
 	Intent      string
+	Output      string // What running the step printed, when the source captured it
 }
 
 // IntentAnalyzer groups commands into logical steps and infers purpose.
@@ -156,6 +157,11 @@ func (a *IntentAnalyzer) Analyze(entries []history.Entry) []CommandGroup {
 				startNewGroup = true
 			}
 
+			// Start new group when the next command ran on another machine
+			if prevEntry.Source != entry.Source {
+				startNewGroup = true
+			}
+
 			// Start new group if there's a significant time gap
 			if a.hasTimeGap(prevEntry, entry) {
 				startNewGroup = true
@@ -207,11 +213,14 @@ func (a *IntentAnalyzer) inferIntent(command string) string {
 }
 
 // hasTimeGap checks if there's a significant time gap between entries.
+// The gap is measured from when the previous command finished, so a
+// long-running build followed immediately by the next step stays grouped.
 func (a *IntentAnalyzer) hasTimeGap(prev, curr history.Entry) bool {
 	if !prev.HasTime || !curr.HasTime {
 		return false
 	}
-	return curr.Timestamp.Sub(prev.Timestamp) > a.threshold
+	finished := prev.Timestamp.Add(prev.Duration)
+	return curr.Timestamp.Sub(finished) > a.threshold
 }
 
 // finalizeGroup sets the title and description for a group.
@@ -233,6 +242,37 @@ func (a *IntentAnalyzer) finalizeGroup(group *CommandGroup) {
 
 	// Generate description based on commands
 	group.Description = generateDescription(group.Commands)
+
+	group.Output = expectedOutput(group.Commands)
+}
+
+// expectedOutput combines what a step's commands printed. When several
+// commands printed something, each output is headed by its command so the
+// reader can tell them apart.
+func expectedOutput(commands []history.Entry) string {
+	var printed []history.Entry
+	for _, cmd := range commands {
+		if cmd.Output != "" {
+			printed = append(printed, cmd)
+		}
+	}
+
+	switch len(printed) {
+	case 0:
+		return ""
+	case 1:
+		return printed[0].Output
+	}
+
+	var parts []string
+	for _, cmd := range printed {
+		command, _, multiline := strings.Cut(cmd.Command, "\n")
+		if multiline {
+			command += " ..."
+		}
+		parts = append(parts, "$ "+command+"\n"+cmd.Output)
+	}
+	return strings.Join(parts, "\n")
 }
 
 // extractTool returns the primary tool/command being used.
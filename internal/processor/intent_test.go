@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestIntentAnalyzer_TimeGapUsesDuration(t *testing.T) {
+	analyzer := NewIntentAnalyzer().WithThreshold(60 * time.Second)
+
+	now := time.Now()
+
+	entries := []history.Entry{
+		// Build runs for 10 minutes, next command follows right after it finishes
+		{Number: 1, Command: "make release", Timestamp: now, HasTime: true, Duration: 10 * time.Minute},
+		{Number: 2, Command: "make install", Timestamp: now.Add(10*time.Minute + 5*time.Second), HasTime: true},
+		// Then a genuine pause
+		{Number: 3, Command: "make clean", Timestamp: now.Add(20 * time.Minute), HasTime: true},
+	}
+
+	groups := analyzer.Analyze(entries)
+
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+
+	if len(groups[0].Commands) != 2 {
+		t.Errorf("expected long-running command to stay grouped with its follow-up, got %d commands", len(groups[0].Commands))
+	}
+}
+
+func TestIntentAnalyzer_GroupsByWorkflow(t *testing.T) {
+	analyzer := NewIntentAnalyzer()
+
+	entries := []history.Entry{
+		{Number: 1, Command: "git add ."},
+		{Number: 2, Command: "git commit -m 'fix'"},
+		{Number: 3, Command: "docker build -t app ."},
+	}
+
+	groups := analyzer.Analyze(entries)
+
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+
+	if groups[0].Title != "Commit and push changes" {
+		t.Errorf("expected git-commit workflow title, got %q", groups[0].Title)
+	}
+
+	if groups[1].Intent != "docker-build" {
+		t.Errorf("expected docker-build intent, got %q", groups[1].Intent)
+	}
+}
+
+func TestIntentAnalyzer_ExpectedOutput(t *testing.T) {
+	analyzer := NewIntentAnalyzer()
+
+	entries := []history.Entry{
+		{Number: 1, Command: "kubectl get pods", Output: "api-0   1/1"},
+		{Number: 2, Command: "kubectl rollout restart deploy/api"},
+		{Number: 3, Command: "kubectl rollout status deploy/api", Output: "successfully rolled out"},
+	}
+
+	groups := analyzer.Analyze(entries)
+
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(groups))
+	}
+
+	expected := "$ kubectl get pods\napi-0   1/1\n$ kubectl rollout status deploy/api\nsuccessfully rolled out"
+	if groups[0].Output != expected {
+		t.Errorf("expected %q, got %q", expected, groups[0].Output)
+	}
+
+	single := analyzer.Analyze(entries[:2])
+	if single[0].Output != "api-0   1/1" {
+		t.Errorf("expected bare output for a single command, got %q", single[0].Output)
+	}
+}
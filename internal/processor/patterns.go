@@ -1,6 +1,9 @@
 package processor
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
 
 // Pattern defines a regex pattern for detecting and redacting sensitive data.
 type Pattern struct {
@@ -8,86 +11,123 @@ type Pattern struct {
 	Regex       *regexp.Regexp
 	Replacement string
 	FullRemove  bool // If true, remove entire command instead of redacting
+
+	// Optional refinements, used by rules imported from gitleaks
+	Keywords    []string           // Regex only runs on text containing one of these (case-insensitive)
+	SecretGroup int                // Capture group holding the secret; only it is replaced, by Replacement taken literally
+	MinEntropy  float64            // Secrets with less Shannon entropy are left alone
+	Allowlists  []PatternAllowlist // Matches any of these allow are left alone
 }
 
-// DefaultPatterns returns the built-in secret detection patterns.
-// NOTE: Order matters! More specific patterns should come before general ones.
-func DefaultPatterns() []Pattern {
-	return []Pattern{
-		// Password flags - long flags first (more specific)
-		// Handle quoted values (may contain spaces)
-		{
-			Name:        "password-flag-quoted-double",
-			Regex:       regexp.MustCompile(`(--password[=\s]+)"([^"]+)"`),
-			Replacement: `${1}"<REDACTED>"`,
-		},
-		{
-			Name:        "password-flag-quoted-single",
-			Regex:       regexp.MustCompile(`(--password[=\s]+)'([^']+)'`),
-			Replacement: `${1}'<REDACTED>'`,
-		},
-		// Handle unquoted values
-		{
-			Name:        "password-flag-unquoted",
-			Regex:       regexp.MustCompile(`(--password[=\s]+)([^'"'\s]+)`),
-			Replacement: "${1}<REDACTED>",
-		},
-		{
-			Name:        "passwd-flag",
-			Regex:       regexp.MustCompile(`(--passwd[=\s]+)(['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}${2}<REDACTED>${4}",
-		},
-		// MySQL short -p flag (must NOT be preceded by another dash)
-		{
-			Name:        "mysql-password",
-			Regex:       regexp.MustCompile(`(\s-p)(['"]?)([^'"'\s-][^'"'\s]*)(['"]?)`),
-			Replacement: "${1}${2}<REDACTED>${4}",
-		},
+// PatternAllowlist exempts some matches of a pattern, like the allowlist of a
+// gitleaks rule.
+type PatternAllowlist struct {
+	Regexes     []*regexp.Regexp
+	RegexTarget string   // What Regexes are matched against: "secret" (default), "match" or "line"
+	Stopwords   []string // Secrets containing one of these are allowed
+	RequireAll  bool     // Allow only when both a regex and a stopword match, where both are given
+}
 
-		// Token and API key flags
-		{
-			Name:        "token-flag",
-			Regex:       regexp.MustCompile(`(--token[=\s]+['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-		{
-			Name:        "api-key-flag",
-			Regex:       regexp.MustCompile(`(--api-key[=\s]+['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-		{
-			Name:        "secret-flag",
-			Regex:       regexp.MustCompile(`(--secret[=\s]+['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
+// mayMatch is the keyword prefilter: it reports whether text contains one of
+// the pattern's keywords, and so whether the regex needs to run at all.
+func (p Pattern) mayMatch(text string) bool {
+	if len(p.Keywords) == 0 {
+		return true
+	}
+	lower := strings.ToLower(text)
+	for _, keyword := range p.Keywords {
+		if strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
 
-		// Environment variable exports
-		{
-			Name:        "api-key-export",
-			Regex:       regexp.MustCompile(`(export\s+[A-Z_]*API[_]?KEY\s*=\s*['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-		{
-			Name:        "secret-export",
-			Regex:       regexp.MustCompile(`(export\s+[A-Z_]*SECRET[A-Z_]*\s*=\s*['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-		{
-			Name:        "password-export",
-			Regex:       regexp.MustCompile(`(export\s+[A-Z_]*PASS(?:WORD)?[A-Z_]*\s*=\s*['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-		{
-			Name:        "token-export",
-			Regex:       regexp.MustCompile(`(export\s+[A-Z_]*TOKEN\s*=\s*['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-		{
-			Name:        "credentials-export",
-			Regex:       regexp.MustCompile(`(export\s+[A-Z_]*CRED(?:ENTIAL)?S?[A-Z_]*\s*=\s*['"]?)([^'"'\s]+)(['"]?)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
+// secretSpan returns where the secret lies in match m: the SecretGroup, or
+// the first group that matched anything if it did not, or else the whole
+// match.
+func (p Pattern) secretSpan(m []int) (int, int) {
+	if p.SecretGroup <= 0 {
+		return m[0], m[1]
+	}
+	if g := p.SecretGroup; 2*g+1 < len(m) && m[2*g] >= 0 && m[2*g] < m[2*g+1] {
+		return m[2*g], m[2*g+1]
+	}
+	for g := 1; 2*g+1 < len(m); g++ {
+		if m[2*g] >= 0 && m[2*g] < m[2*g+1] {
+			return m[2*g], m[2*g+1]
+		}
+	}
+	return m[0], m[1]
+}
 
+// allows reports whether a match is exempt from redaction. line is the whole
+// text being sanitized.
+func (p Pattern) allows(line, match, secret string) bool {
+	if p.MinEntropy > 0 && shannonEntropy(secret) < p.MinEntropy {
+		return true
+	}
+	for _, allowlist := range p.Allowlists {
+		if allowlist.allows(line, match, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a PatternAllowlist) allows(line, match, secret string) bool {
+	var checks []bool
+
+	if len(a.Regexes) > 0 {
+		target := secret
+		switch a.RegexTarget {
+		case "match":
+			target = match
+		case "line":
+			target = line
+		}
+		matched := false
+		for _, re := range a.Regexes {
+			if re.MatchString(target) {
+				matched = true
+				break
+			}
+		}
+		checks = append(checks, matched)
+	}
+
+	if len(a.Stopwords) > 0 {
+		lower := strings.ToLower(secret)
+		found := false
+		for _, word := range a.Stopwords {
+			if strings.Contains(lower, strings.ToLower(word)) {
+				found = true
+				break
+			}
+		}
+		checks = append(checks, found)
+	}
+
+	if len(checks) == 0 {
+		return false
+	}
+	for _, ok := range checks {
+		if ok && !a.RequireAll {
+			return true
+		}
+		if !ok && a.RequireAll {
+			return false
+		}
+	}
+	return a.RequireAll
+}
+
+// DefaultPatterns returns the built-in secret detection patterns. Secrets
+// passed as flag values or variable assignments are found by
+// DefaultShellRules instead, which see the command the way the shell does.
+// NOTE: Order matters! More specific patterns should come before general ones.
+func DefaultPatterns() []Pattern {
+	return []Pattern{
 		// AWS credentials
 		{
 			Name:        "aws-access-key-id",
@@ -369,32 +409,11 @@ func DefaultPatterns() []Pattern {
 			Replacement: "${1}<REDACTED>${3}",
 		},
 
-		// Inline variable assignments before commands
-		{
-			Name:        "inline-secret-var",
-			Regex:       regexp.MustCompile(`(\b(?:PASSWORD|SECRET|TOKEN|API_KEY)\s*=\s*['"]?)([^'"'\s]+)(['"]?\s)`),
-			Replacement: "${1}<REDACTED>${3}",
-		},
-
 		// curl with data containing passwords
 		{
 			Name:        "curl-password-data",
 			Regex:       regexp.MustCompile(`(-d\s+['"]?[^'"]*(?:password|passwd|secret|token)['"]*\s*[=:]\s*['"]?)([^'"&\s]+)(['"]?)`),
 			Replacement: "${1}<REDACTED>${3}",
 		},
-
-		// Docker/container secrets
-		{
-			Name:        "docker-secret-env",
-			Regex:       regexp.MustCompile(`(-e\s+[A-Z_]*(?:PASSWORD|SECRET|TOKEN|API_KEY)[A-Z_]*=)([^\s]+)`),
-			Replacement: "${1}<REDACTED>",
-		},
-
-		// kubectl secret data
-		{
-			Name:        "kubectl-secret",
-			Regex:       regexp.MustCompile(`(--from-literal=[A-Za-z_-]*(?:password|secret|token|key)[A-Za-z_-]*=)([^\s]+)`),
-			Replacement: "${1}<REDACTED>",
-		},
 	}
 }
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"slices"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+// Sanitized is a list of entries that has been through a Sanitizer. Only this
+// package can fill one in, so code that must never see secrets, such as the
+// AI client, takes a Sanitized rather than raw entries.
+type Sanitized struct {
+	entries []history.Entry
+}
+
+// SanitizedGroups are command groups built from sanitized entries.
+type SanitizedGroups struct {
+	groups []CommandGroup
+}
+
+// Sanitize redacts entries like Process, returning them as Sanitized.
+func (s *Sanitizer) Sanitize(entries []history.Entry) (Sanitized, []Redaction) {
+	result, redactions := s.Process(entries)
+	return Sanitized{entries: result}, redactions
+}
+
+// Entries returns a copy of the sanitized entries.
+func (s Sanitized) Entries() []history.Entry {
+	return slices.Clone(s.entries)
+}
+
+// Len returns the number of entries.
+func (s Sanitized) Len() int {
+	return len(s.entries)
+}
+
+// Without returns the entries other than those at the given indexes.
+// Dropping entries reveals nothing, so the result is still sanitized.
+func (s Sanitized) Without(remove map[int]bool) Sanitized {
+	var result []history.Entry
+	for i, entry := range s.entries {
+		if !remove[i] {
+			result = append(result, entry)
+		}
+	}
+	return Sanitized{entries: result}
+}
+
+// SingleGroup returns all the entries as one untitled group.
+func (s Sanitized) SingleGroup() SanitizedGroups {
+	return SanitizedGroups{groups: []CommandGroup{{Commands: s.Entries()}}}
+}
+
+// ProcessSanitized deduplicates sanitized entries. Deduplication only drops
+// and collapses commands, so the result is still sanitized.
+func (d *Dedup) ProcessSanitized(entries Sanitized) Sanitized {
+	return Sanitized{entries: d.Process(entries.entries)}
+}
+
+// AnalyzeSanitized groups sanitized entries like Analyze.
+func (a *IntentAnalyzer) AnalyzeSanitized(entries Sanitized) SanitizedGroups {
+	return SanitizedGroups{groups: a.Analyze(entries.entries)}
+}
+
+// Groups returns a copy of the groups.
+func (g SanitizedGroups) Groups() []CommandGroup {
+	groups := slices.Clone(g.groups)
+	for i := range groups {
+		groups[i].Commands = slices.Clone(groups[i].Commands)
+	}
+	return groups
+}
+
+// Len returns the number of groups.
+func (g SanitizedGroups) Len() int {
+	return len(g.groups)
+}
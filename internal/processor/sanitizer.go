@@ -1,6 +1,9 @@
 package processor
 
 import (
+	"regexp"
+	"strings"
+
 	"github.com/mrf/runbook-generator/internal/history"
 )
 
@@ -13,33 +16,63 @@ type Redaction struct {
 
 // Sanitizer removes or redacts sensitive information from commands.
 type Sanitizer struct {
+	rules      []ShellRule
 	patterns   []Pattern
 	allowlist  map[string]bool
+	entropy    EntropyConfig
 	strictMode bool
+	variables  *variables
+	scrub      ScrubConfig
+	hostRegex  *regexp.Regexp // Internal host names, built from scrub.InternalDomains
+	pseudonyms *pseudonyms
 }
 
 // NewSanitizer creates a new sanitizer with default patterns.
 func NewSanitizer() *Sanitizer {
 	return &Sanitizer{
-		patterns:  DefaultPatterns(),
-		allowlist: make(map[string]bool),
+		rules:      DefaultShellRules(),
+		patterns:   DefaultPatterns(),
+		allowlist:  make(map[string]bool),
+		entropy:    DefaultEntropyConfig(),
+		variables:  newVariables(),
+		pseudonyms: newPseudonyms(),
 	}
 }
 
-// WithPatterns adds custom patterns to the sanitizer.
+// WithPatterns adds custom patterns to the sanitizer. They run before the
+// default patterns, so a specific custom format is not half-redacted by a
+// generic pattern first.
 func (s *Sanitizer) WithPatterns(patterns []Pattern) *Sanitizer {
-	s.patterns = append(s.patterns, patterns...)
+	s.patterns = append(append([]Pattern(nil), patterns...), s.patterns...)
+	return s
+}
+
+// WithShellRules adds custom shell rules to the sanitizer, ahead of the
+// default rules.
+func (s *Sanitizer) WithShellRules(rules []ShellRule) *Sanitizer {
+	s.rules = append(append([]ShellRule(nil), rules...), s.rules...)
 	return s
 }
 
-// WithAllowlist sets values that should not be redacted.
+// WithAllowlist sets values that should not be redacted. A secret is left as
+// it is only when it equals an allowlisted value, so a short entry cannot
+// exempt a longer secret that happens to contain it.
 func (s *Sanitizer) WithAllowlist(values []string) *Sanitizer {
 	for _, v := range values {
-		s.allowlist[v] = true
+		if v != "" {
+			s.allowlist[v] = true
+		}
 	}
 	return s
 }
 
+// WithEntropy configures the high-entropy secret detector. A MinLength of 0
+// turns it off.
+func (s *Sanitizer) WithEntropy(config EntropyConfig) *Sanitizer {
+	s.entropy = config
+	return s
+}
+
 // WithStrictMode enables strict mode which preserves originals for review.
 func (s *Sanitizer) WithStrictMode(strict bool) *Sanitizer {
 	s.strictMode = strict
@@ -59,17 +92,42 @@ func (s *Sanitizer) Process(entries []history.Entry) ([]history.Entry, []Redacti
 		redactions = append(redactions, entryRedactions...)
 	}
 
+	// Output is sanitized once every command has been, so a secret a later
+	// command sets is known when an earlier command prints it
+	for i, entry := range result {
+		var entryRedactions []Redaction
+		result[i], entryRedactions = s.sanitizeAround(entry)
+		redactions = append(redactions, entryRedactions...)
+	}
+
 	return result, redactions
 }
 
-// sanitizeEntry processes a single entry and returns the sanitized version.
+// sanitizeEntry sanitizes the command of a single entry, returning nil if the
+// whole entry must be dropped.
 func (s *Sanitizer) sanitizeEntry(entry history.Entry) (*history.Entry, []Redaction) {
 	var redactions []Redaction
 	command := entry.Command
 	original := command
 
+	for _, rule := range s.rules {
+		if newCommand, ok := s.applyRule(rule, command); ok {
+			redactions = append(redactions, Redaction{
+				EntryNumber: entry.Number,
+				PatternName: rule.Name,
+				Original: func() string {
+					if s.strictMode {
+						return original
+					}
+					return ""
+				}(),
+			})
+			command = newCommand
+		}
+	}
+
 	for _, pattern := range s.patterns {
-		if !pattern.Regex.MatchString(command) {
+		if !s.matches(pattern, command) {
 			continue
 		}
 
@@ -86,7 +144,7 @@ func (s *Sanitizer) sanitizeEntry(entry history.Entry) (*history.Entry, []Redact
 		}
 
 		// Apply the replacement
-		newCommand := pattern.Regex.ReplaceAllString(command, pattern.Replacement)
+		newCommand := s.replace(pattern, command)
 		if newCommand != command {
 			redactions = append(redactions, Redaction{
 				EntryNumber: entry.Number,
@@ -102,19 +160,223 @@ func (s *Sanitizer) sanitizeEntry(entry history.Entry) (*history.Entry, []Redact
 		}
 	}
 
-	// Return modified entry
+	// Catch secrets no pattern recognised, e.g. custom internal tokens
+	if redacted, ok := s.redactHighEntropy(command, isGitCommand(entry.Command)); ok {
+		redactions = append(redactions, Redaction{
+			EntryNumber: entry.Number,
+			PatternName: EntropyPatternName,
+			Original: func() string {
+				if s.strictMode {
+					return original
+				}
+				return ""
+			}(),
+		})
+		command = redacted
+	}
+	command = renderPlaceholders(command)
+
+	scrubbed, names := s.scrubIdentifiers(command)
+	for _, name := range names {
+		redactions = append(redactions, Redaction{
+			EntryNumber: entry.Number,
+			PatternName: name,
+			Original: func() string {
+				if s.strictMode {
+					return original
+				}
+				return ""
+			}(),
+		})
+	}
+	command = scrubbed
+
 	result := entry
 	result.Command = command
 	return &result, redactions
 }
 
-// SanitizeString sanitizes a single command string.
-func (s *Sanitizer) SanitizeString(command string) string {
+// sanitizeAround sanitizes what was recorded with an entry's command: its
+// output, the paths it referenced and where it ran.
+func (s *Sanitizer) sanitizeAround(entry history.Entry) (history.Entry, []Redaction) {
+	output, redactions := s.sanitizeText(entry, entry.Output, "[REDACTED - output contains sensitive data]")
+
+	var paths []string
+	for _, path := range entry.Paths {
+		path, pathRedactions := s.sanitizeText(entry, path, "[REDACTED]")
+		paths = append(paths, path)
+		redactions = append(redactions, pathRedactions...)
+	}
+
+	result := entry
+	result.Output = output
+	result.Paths = paths
+
+	result, names := s.scrubContext(result)
+	for _, name := range names {
+		redactions = append(redactions, Redaction{EntryNumber: entry.Number, PatternName: name})
+	}
+	return result, redactions
+}
+
+// sanitizeText redacts secrets from text that belongs to an entry but is not
+// a command: the output it printed, or the paths it referenced. Text matching
+// a full-remove pattern (e.g. a printed private key) is replaced as a whole
+// by removed, since the command itself is still worth keeping.
+func (s *Sanitizer) sanitizeText(entry history.Entry, text, removed string) (string, []Redaction) {
+	output := text
+	if output == "" {
+		return output, nil
+	}
+
+	var redactions []Redaction
+	redaction := func(name string) Redaction {
+		r := Redaction{EntryNumber: entry.Number, PatternName: name}
+		if s.strictMode {
+			r.Original = text
+		}
+		return r
+	}
+
+	// Secrets found in commands, e.g. a password the command exported,
+	// become the same variable here
+	output, patterns := s.variables.replaceKnown(output)
+	for _, name := range patterns {
+		redactions = append(redactions, redaction(name))
+	}
+
+	for _, rule := range s.rules {
+		if sanitized, ok := s.applyRuleToText(rule, output); ok {
+			redactions = append(redactions, redaction(rule.Name))
+			output = sanitized
+		}
+	}
+
 	for _, pattern := range s.patterns {
-		if pattern.FullRemove && pattern.Regex.MatchString(command) {
+		if !s.matches(pattern, output) {
+			continue
+		}
+
+		if pattern.FullRemove {
+			return removed, []Redaction{redaction(pattern.Name)}
+		}
+
+		if sanitized := s.replace(pattern, output); sanitized != output {
+			redactions = append(redactions, redaction(pattern.Name))
+			output = sanitized
+		}
+	}
+
+	if redacted, ok := s.redactHighEntropy(output, isGitCommand(entry.Command)); ok {
+		redactions = append(redactions, redaction(EntropyPatternName))
+		output = redacted
+	}
+
+	output, names := s.scrubIdentifiers(renderPlain(output))
+	for _, name := range names {
+		redactions = append(redactions, redaction(name))
+	}
+	return output, redactions
+}
+
+// SanitizeString sanitizes a single command string. Its variables and
+// pseudonyms are numbered for this command alone; they are not shared with
+// Process.
+func (s *Sanitizer) SanitizeString(command string) string {
+	single := *s
+	single.variables = newVariables()
+	single.pseudonyms = newPseudonyms()
+
+	gitCommand := isGitCommand(command)
+	for _, rule := range single.rules {
+		command, _ = single.applyRule(rule, command)
+	}
+	for _, pattern := range single.patterns {
+		if pattern.FullRemove && single.matches(pattern, command) {
 			return "[REDACTED - contains sensitive data]"
 		}
-		command = pattern.Regex.ReplaceAllString(command, pattern.Replacement)
+		command = single.replace(pattern, command)
 	}
+	command, _ = single.redactHighEntropy(command, gitCommand)
+	command, _ = single.scrubIdentifiers(renderPlaceholders(command))
 	return command
 }
+
+// matches reports whether pattern matches text anywhere that is
+// redactable.
+func (s *Sanitizer) matches(pattern Pattern, text string) bool {
+	if !pattern.mayMatch(text) {
+		return false
+	}
+	for _, m := range pattern.Regex.FindAllStringSubmatchIndex(text, -1) {
+		if s.redactable(pattern, text, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// replace applies a pattern's replacement to every match in text that is not
+// allowlisted, with the placeholder naming the secret's variable. With a
+// SecretGroup only the secret itself is replaced.
+func (s *Sanitizer) replace(pattern Pattern, text string) string {
+	if !pattern.mayMatch(text) {
+		return text
+	}
+
+	var result []byte
+	last := 0
+	for _, m := range pattern.Regex.FindAllStringSubmatchIndex(text, -1) {
+		if !s.redactable(pattern, text, m) {
+			continue
+		}
+		start, end := m[0], m[1]
+		if pattern.SecretGroup > 0 {
+			start, end = pattern.secretSpan(m)
+		}
+		replacement, secret := s.expand(pattern, text, m)
+		result = append(result, text[last:start]...)
+		result = append(result, s.nameSecret(replacement, secret, pattern.Name)...)
+		last = end
+	}
+	return string(append(result, text[last:]...))
+}
+
+// expand returns what match m of pattern is replaced with, and the secret it
+// replaces. Without a SecretGroup, the secret is the part of the match that
+// the placeholder takes the place of: the text between ${1} and ${3} for a
+// "${1}<REDACTED>${3}" replacement.
+func (s *Sanitizer) expand(pattern Pattern, text string, m []int) (string, string) {
+	if pattern.SecretGroup > 0 {
+		start, end := pattern.secretSpan(m)
+		return pattern.Replacement, text[start:end]
+	}
+
+	match := text[m[0]:m[1]]
+	replacement := string(pattern.Regex.ExpandString(nil, pattern.Replacement, text, m))
+	if loc := placeholderRegex.FindStringIndex(replacement); loc != nil {
+		prefix, suffix := replacement[:loc[0]], replacement[loc[1]:]
+		if len(prefix)+len(suffix) <= len(match) && strings.HasPrefix(match, prefix) && strings.HasSuffix(match, suffix) {
+			return replacement, match[len(prefix) : len(match)-len(suffix)]
+		}
+	}
+	return replacement, match
+}
+
+// redactable reports whether match m of pattern should be redacted: its
+// secret must not be allowlisted, nor be allowed by the pattern itself, nor
+// be a variable reference, such as a secret already replaced.
+func (s *Sanitizer) redactable(pattern Pattern, text string, m []int) bool {
+	match := text[m[0]:m[1]]
+	if _, secret := s.expand(pattern, text, m); s.allowlisted(secret) || needsNoRedaction(secret) {
+		return false
+	}
+	start, end := pattern.secretSpan(m)
+	return !pattern.allows(text, match, text[start:end])
+}
+
+// allowlisted reports whether a secret is one of the allowlisted values.
+// Every detector compares the value it would replace, not its surroundings.
+func (s *Sanitizer) allowlisted(secret string) bool {
+	return s.allowlist[secret]
+}
@@ -0,0 +1,305 @@
+package processor
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+// ScrubConfig configures the scrubbing profile, which replaces personal and
+// infrastructure identifiers with pseudonyms so a runbook can be shared
+// outside the organisation: email addresses, IPv4 and IPv6 addresses,
+// internal host names, AWS account IDs and the usernames in home directory
+// paths. The same identifier always gets the same pseudonym, so the runbook
+// keeps its structure. Scrubbing is off unless Enabled is set.
+type ScrubConfig struct {
+	Enabled         bool
+	InternalDomains []string // Host names ending in one of these are internal, e.g. corp.example.com
+}
+
+// Names recorded for scrubbed identifiers, in the order they are scrubbed.
+const (
+	EmailScrubName      = "email-address"
+	AWSAccountScrubName = "aws-account-id"
+	HostScrubName       = "internal-host"
+	IPv6ScrubName       = "ipv6-address"
+	IPv4ScrubName       = "ipv4-address"
+	UsernameScrubName   = "home-username"
+)
+
+var (
+	emailRegex = regexp.MustCompile(`[A-Za-z0-9._%+-]+@((?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?\.)+[A-Za-z]{2,})`)
+
+	// Account IDs are only recognised where they are known to be one: in
+	// ARNs, ECR registries and account flags, variables and JSON keys
+	awsAccountRegex = regexp.MustCompile(`arn:aws[a-z-]*:[a-z0-9-]*:[a-z0-9-]*:(\d{12})\b|\b(\d{12})\.dkr\.ecr\.|(?i:account(?:[_-]?ids?)?)["']?(?:\s*[=:]\s*|\s+)["']?(\d{12})\b`)
+
+	ipv4Regex = regexp.MustCompile(`(?:\d{1,3}\.){3}\d{1,3}`)
+	ipv6Regex = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+
+	homeRegex = regexp.MustCompile(`(/home/|/Users/)([A-Za-z0-9._-]+)`)
+)
+
+// serviceUsers are not people: git@github.com and /Users/Shared stay.
+var serviceUsers = map[string]bool{"git": true, "Shared": true, "linuxbrew": true}
+
+// pseudonyms hands out one pseudonym per distinct identifier of a kind, so a
+// host is host-1 in every command and in its output.
+type pseudonyms struct {
+	byValue map[string]string
+	counts  map[string]int
+}
+
+func newPseudonyms() *pseudonyms {
+	return &pseudonyms{byValue: make(map[string]string), counts: make(map[string]int)}
+}
+
+// get returns the pseudonym of value, formatting a new one from the number of
+// values of its kind seen so far.
+func (p *pseudonyms) get(kind, value string, format func(n int) string) string {
+	key := kind + "\x00" + value
+	if pseudonym, ok := p.byValue[key]; ok {
+		return pseudonym
+	}
+	p.counts[kind]++
+	pseudonym := format(p.counts[kind])
+	p.byValue[key] = pseudonym
+	return pseudonym
+}
+
+func (p *pseudonyms) user(name string) string {
+	return p.get("user", name, func(n int) string { return fmt.Sprintf("user-%d", n) })
+}
+
+func (p *pseudonyms) host(name string) string {
+	return p.get("host", strings.ToLower(name), func(n int) string { return fmt.Sprintf("host-%d", n) })
+}
+
+func (p *pseudonyms) awsAccount(id string) string {
+	return p.get("aws-account", id, func(n int) string { return fmt.Sprintf("%012d", n) })
+}
+
+func (p *pseudonyms) ipv4(addr netip.Addr) string {
+	return p.get("ipv4", addr.String(), func(n int) string {
+		return fmt.Sprintf("10.0.%d.%d", (n-1)/254, (n-1)%254+1)
+	})
+}
+
+func (p *pseudonyms) ipv6(addr netip.Addr) string {
+	return p.get("ipv6", addr.String(), func(n int) string { return fmt.Sprintf("fd00::%x", n) })
+}
+
+// WithScrubbing configures the scrubbing profile.
+func (s *Sanitizer) WithScrubbing(config ScrubConfig) *Sanitizer {
+	s.scrub = config
+	s.hostRegex = nil
+
+	var domains []string
+	for _, domain := range config.InternalDomains {
+		if domain = strings.Trim(strings.ToLower(domain), "."); domain != "" {
+			domains = append(domains, regexp.QuoteMeta(domain))
+		}
+	}
+	if len(domains) > 0 {
+		s.hostRegex = regexp.MustCompile(`(?i)(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)*(?:` + strings.Join(domains, "|") + `)`)
+	}
+	return s
+}
+
+// scrubIdentifiers replaces the identifiers in text with pseudonyms, if the
+// scrubbing profile is enabled. It returns the names of the kinds it
+// replaced.
+func (s *Sanitizer) scrubIdentifiers(text string) (string, []string) {
+	if !s.scrub.Enabled {
+		return text, nil
+	}
+
+	var scrubbed []string
+	for _, step := range []struct {
+		name  string
+		regex *regexp.Regexp
+		scrub func(text string, m []int) string
+	}{
+		{EmailScrubName, emailRegex, s.scrubEmail},
+		{AWSAccountScrubName, awsAccountRegex, s.scrubAWSAccount},
+		{HostScrubName, s.hostRegex, s.scrubHost},
+		{IPv6ScrubName, ipv6Regex, s.scrubIPv6},
+		{IPv4ScrubName, ipv4Regex, s.scrubIPv4},
+		{UsernameScrubName, homeRegex, s.scrubHome},
+	} {
+		if step.regex == nil {
+			continue
+		}
+		if replaced, ok := s.replaceIdentifiers(step.regex, text, step.scrub); ok {
+			scrubbed = append(scrubbed, step.name)
+			text = replaced
+		}
+	}
+	return text, scrubbed
+}
+
+// scrubContext replaces the identifiers in what was recorded about where an
+// entry ran, if the scrubbing profile is enabled. The directory and git branch
+// are scrubbed like text; the host and source label are replaced as a whole,
+// since a bare machine name identifies it as well as its internal host name
+// does. It returns the names of the kinds it replaced.
+func (s *Sanitizer) scrubContext(entry history.Entry) (history.Entry, []string) {
+	if !s.scrub.Enabled {
+		return entry, nil
+	}
+
+	var scrubbed []string
+	for _, field := range []*string{&entry.Dir, &entry.GitBranch} {
+		text, names := s.scrubIdentifiers(*field)
+		*field = text
+		for _, name := range names {
+			if !slices.Contains(scrubbed, name) {
+				scrubbed = append(scrubbed, name)
+			}
+		}
+	}
+
+	machine := false
+	for _, field := range []*string{&entry.Host, &entry.Source} {
+		if *field != "" && !s.allowlisted(*field) {
+			*field = s.pseudonyms.host(*field)
+			machine = true
+		}
+	}
+	if machine && !slices.Contains(scrubbed, HostScrubName) {
+		scrubbed = append(scrubbed, HostScrubName)
+	}
+	return entry, scrubbed
+}
+
+// replaceIdentifiers replaces each match of re in text with what scrub
+// returns for it; an empty string keeps the match. Matches that are
+// allowlisted as a whole are kept too.
+func (s *Sanitizer) replaceIdentifiers(re *regexp.Regexp, text string, scrub func(text string, m []int) string) (string, bool) {
+	var sb strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		if s.allowlisted(text[m[0]:m[1]]) {
+			continue
+		}
+		replacement := scrub(text, m)
+		if replacement == "" {
+			continue
+		}
+		sb.WriteString(text[last:m[0]])
+		sb.WriteString(replacement)
+		last = m[1]
+	}
+	if last == 0 {
+		return text, false
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), true
+}
+
+// scrubEmail replaces the user of an address, and its domain with the
+// internal host's pseudonym or else example.com, so ssh deploy@db.corp.example.com
+// still reads as a login to a host.
+func (s *Sanitizer) scrubEmail(text string, m []int) string {
+	user, domain := text[m[0]:m[2]-1], text[m[2]:m[3]]
+	if serviceUsers[user] || s.allowlisted(user) {
+		return ""
+	}
+
+	scrubbed := "example.com"
+	if s.hostRegex != nil {
+		if loc := s.hostRegex.FindStringIndex(domain); loc != nil && loc[0] == 0 && loc[1] == len(domain) {
+			scrubbed = s.pseudonyms.host(domain)
+		}
+	}
+	return s.pseudonyms.user(user) + "@" + scrubbed
+}
+
+func (s *Sanitizer) scrubAWSAccount(text string, m []int) string {
+	for g := 1; 2*g+1 < len(m); g++ {
+		if m[2*g] >= 0 {
+			if s.allowlisted(text[m[2*g]:m[2*g+1]]) {
+				return ""
+			}
+			return text[m[0]:m[2*g]] + s.pseudonyms.awsAccount(text[m[2*g]:m[2*g+1]]) + text[m[2*g+1]:m[1]]
+		}
+	}
+	return ""
+}
+
+// scrubHost replaces a whole host name ending in an internal domain; names
+// that merely contain one, like notcorp.example.com, are kept.
+func (s *Sanitizer) scrubHost(text string, m []int) string {
+	if m[0] > 0 && isHostChar(text[m[0]-1]) {
+		return ""
+	}
+	if rest := text[m[1]:]; rest != "" && (isHostChar(rest[0]) || len(rest) > 1 && rest[0] == '.' && isAlnum(rest[1])) {
+		return ""
+	}
+	return s.pseudonyms.host(text[m[0]:m[1]])
+}
+
+// scrubIPv6 replaces IPv6 addresses other than loopback and unspecified
+// ones. Matches that are not addresses, or are part of a longer word like
+// std::string, are kept.
+func (s *Sanitizer) scrubIPv6(text string, m []int) string {
+	if m[0] > 0 && (isAlnum(text[m[0]-1]) || text[m[0]-1] == ':' || text[m[0]-1] == '.') {
+		return ""
+	}
+	if rest := text[m[1]:]; rest != "" && (isAlnum(rest[0]) || rest[0] == ':' || len(rest) > 1 && rest[0] == '.' && isAlnum(rest[1])) {
+		return ""
+	}
+	addr, err := netip.ParseAddr(text[m[0]:m[1]])
+	if err != nil || !addr.Is6() || addr.IsLoopback() || addr.IsUnspecified() {
+		return ""
+	}
+	return s.pseudonyms.ipv6(addr)
+}
+
+// scrubIPv4 replaces IPv4 addresses other than loopback, unspecified and
+// netmask ones. Version numbers like v1.2.3.4, ==1.2.3.4 and 1.2.3.4.5 are
+// kept.
+func (s *Sanitizer) scrubIPv4(text string, m []int) string {
+	if m[0] > 0 && (isHostChar(text[m[0]-1]) || text[m[0]-1] == '.') {
+		return ""
+	}
+	if m[0] > 1 && text[m[0]-1] == '=' && strings.IndexByte("=<>~!", text[m[0]-2]) >= 0 {
+		return ""
+	}
+	if rest := text[m[1]:]; rest != "" && (isAlnum(rest[0]) || len(rest) > 1 && rest[0] == '.' && isAlnum(rest[1])) {
+		return ""
+	}
+	addr, err := netip.ParseAddr(text[m[0]:m[1]])
+	if err != nil || addr.IsLoopback() || addr.IsUnspecified() || isNetmask(addr) {
+		return ""
+	}
+	return s.pseudonyms.ipv4(addr)
+}
+
+func (s *Sanitizer) scrubHome(text string, m []int) string {
+	user := text[m[4]:m[5]]
+	if serviceUsers[user] || s.allowlisted(user) {
+		return ""
+	}
+	return text[m[2]:m[3]] + s.pseudonyms.user(user)
+}
+
+// isNetmask reports whether addr is a run of one bits followed by zero bits,
+// like 255.255.255.0.
+func isNetmask(addr netip.Addr) bool {
+	b := addr.As4()
+	inverted := ^(uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]))
+	return inverted&(inverted+1) == 0
+}
+
+func isAlnum(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isHostChar(c byte) bool {
+	return isAlnum(c) || c == '-' || c == '_'
+}
@@ -0,0 +1,170 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestSanitizer_Scrubbing(t *testing.T) {
+	sanitizer := NewSanitizer().
+		WithEntropy(EntropyConfig{}).
+		WithScrubbing(ScrubConfig{Enabled: true, InternalDomains: []string{"corp.acme.com", ".acme.internal"}}).
+		WithAllowlist([]string{"203.0.113.7"})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "email address",
+			input:    "git commit --author 'Jane Doe <jane.doe@acme.com>'",
+			expected: "git commit --author 'Jane Doe <user-1@example.com>'",
+		},
+		{
+			name:     "login to an internal host",
+			input:    "ssh deploy@db01.corp.acme.com",
+			expected: "ssh user-1@host-1",
+		},
+		{
+			name:     "git remote",
+			input:    "git clone git@github.com:acme/app.git",
+			expected: "git clone git@github.com:acme/app.git",
+		},
+		{
+			name:     "internal hosts",
+			input:    "curl https://api.corp.acme.com/v1 && ping vault.ACME.internal",
+			expected: "curl https://host-1/v1 && ping host-2",
+		},
+		{
+			name:     "host only containing an internal domain",
+			input:    "curl https://notcorp.acme.com https://corp.acme.com.evil.io",
+			expected: "curl https://notcorp.acme.com https://corp.acme.com.evil.io",
+		},
+		{
+			name:     "IPv4 addresses",
+			input:    "ssh 192.168.4.20 -p 22 && curl http://10.1.2.3:8080 && ping 192.168.4.20",
+			expected: "ssh 10.0.0.1 -p 22 && curl http://10.0.0.2:8080 && ping 10.0.0.1",
+		},
+		{
+			name:     "addresses kept",
+			input:    "curl 127.0.0.1 0.0.0.0 203.0.113.7 && ifconfig eth0 netmask 255.255.255.0",
+			expected: "curl 127.0.0.1 0.0.0.0 203.0.113.7 && ifconfig eth0 netmask 255.255.255.0",
+		},
+		{
+			name:     "version numbers",
+			input:    "pip install app==1.2.3.4 && helm upgrade --version v1.2.3.4 x 1.2.3.4.5",
+			expected: "pip install app==1.2.3.4 && helm upgrade --version v1.2.3.4 x 1.2.3.4.5",
+		},
+		{
+			name:     "IPv6 addresses",
+			input:    "ping6 2001:db8:85a3::8a2e:370:7334 && curl http://[fe80::1]:80 && ping6 ::1",
+			expected: "ping6 fd00::1 && curl http://[fd00::2]:80 && ping6 ::1",
+		},
+		{
+			name:     "not IPv6 addresses",
+			input:    "echo std::string 12:30:45 aa:bb:cc:dd:ee:ff",
+			expected: "echo std::string 12:30:45 aa:bb:cc:dd:ee:ff",
+		},
+		{
+			name:     "AWS account IDs",
+			input:    "aws sts assume-role --role-arn arn:aws:iam::123456789012:role/admin && docker push 123456789012.dkr.ecr.us-east-1.amazonaws.com/app",
+			expected: "aws sts assume-role --role-arn arn:aws:iam::000000000001:role/admin && docker push 000000000001.dkr.ecr.us-east-1.amazonaws.com/app",
+		},
+		{
+			name:     "AWS account flag and variable",
+			input:    "export AWS_ACCOUNT_ID=210987654321 && aws organizations describe-account --account-id 123456789012",
+			expected: "export AWS_ACCOUNT_ID=000000000001 && aws organizations describe-account --account-id 000000000002",
+		},
+		{
+			name:     "other 12-digit numbers",
+			input:    "echo 123456789012",
+			expected: "echo 123456789012",
+		},
+		{
+			name:     "home directories",
+			input:    "cp /home/alice/.kube/config /Users/bob/ && ls /Users/Shared",
+			expected: "cp /home/user-1/.kube/config /Users/user-2/ && ls /Users/Shared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := sanitizer.SanitizeString(tt.input); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizer_ScrubbingConsistent(t *testing.T) {
+	sanitizer := NewSanitizer().WithScrubbing(ScrubConfig{Enabled: true, InternalDomains: []string{"corp.acme.com"}})
+
+	entries := []history.Entry{
+		{Number: 1, Command: "ssh alice@bastion.corp.acme.com", Output: "Last login from 192.168.4.20"},
+		{Number: 2, Command: "scp /home/alice/dump.sql bastion.corp.acme.com:/tmp", Output: ""},
+		{Number: 3, Command: "ssh 192.168.4.20 --password hunter2hunter2", Output: ""},
+	}
+	result, redactions := sanitizer.Process(entries)
+
+	expected := []string{
+		"ssh user-1@host-1",
+		"scp /home/user-1/dump.sql host-1:/tmp",
+		`ssh 10.0.0.1 --password "${PASSWORD}"`,
+	}
+	for i, want := range expected {
+		if result[i].Command != want {
+			t.Errorf("entry %d: expected %q, got %q", i+1, want, result[i].Command)
+		}
+	}
+	if result[0].Output != "Last login from 10.0.0.1" {
+		t.Errorf("expected the output scrubbed the same way, got %q", result[0].Output)
+	}
+
+	counts := make(map[string]int)
+	for _, r := range redactions {
+		counts[r.PatternName]++
+	}
+	if counts[EmailScrubName] != 1 || counts[HostScrubName] != 1 || counts[IPv4ScrubName] != 2 || counts[UsernameScrubName] != 1 {
+		t.Errorf("expected scrubbed identifiers recorded, got %v", counts)
+	}
+}
+
+func TestSanitizer_ScrubbingOff(t *testing.T) {
+	input := "ssh alice@db.corp.acme.com 10.1.2.3 /home/alice"
+
+	if result := NewSanitizer().SanitizeString(input); result != input {
+		t.Errorf("expected nothing scrubbed by default, got %q", result)
+	}
+
+	sanitizer := NewSanitizer().WithScrubbing(ScrubConfig{InternalDomains: []string{"corp.acme.com"}})
+	if result := sanitizer.SanitizeString(input); result != input {
+		t.Errorf("expected nothing scrubbed unless enabled, got %q", result)
+	}
+}
+
+func TestSanitizer_ScrubbingContext(t *testing.T) {
+	sanitizer := NewSanitizer().WithScrubbing(ScrubConfig{Enabled: true, InternalDomains: []string{"corp.acme.com"}})
+
+	entries := []history.Entry{
+		{Number: 1, Command: "ssh db1.corp.acme.com", Dir: "/home/alice/src", GitBranch: "alice@acme.com/fix", Host: "laptop", Source: "laptop"},
+		{Number: 2, Command: "uptime", Dir: "/srv", Host: "DB1.corp.acme.com", Source: "ops"},
+	}
+	result, _ := sanitizer.Process(entries)
+
+	for i, want := range []history.Entry{
+		{Dir: "/home/user-1/src", GitBranch: "user-1@example.com/fix", Host: "host-2", Source: "host-2"},
+		{Dir: "/srv", Host: "host-1", Source: "host-3"},
+	} {
+		got := result[i]
+		if got.Dir != want.Dir || got.GitBranch != want.GitBranch || got.Host != want.Host || got.Source != want.Source {
+			t.Errorf("entry %d: expected %q %q %q %q, got %q %q %q %q", i+1,
+				want.Dir, want.GitBranch, want.Host, want.Source, got.Dir, got.GitBranch, got.Host, got.Source)
+		}
+	}
+
+	if result, _ := NewSanitizer().Process(entries); result[0].Dir != "/home/alice/src" || result[1].Source != "ops" {
+		t.Errorf("expected nothing scrubbed unless enabled, got %+v", result)
+	}
+}
@@ -0,0 +1,223 @@
+package processor
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ShellRule finds a secret by where it sits in a command rather than by what
+// it looks like: the value of a flag, of a variable assignment, or a
+// positional argument. The command is split into words the way the shell
+// splits it, so quoting such as --password="a b"c or $'...' does not hide
+// the value.
+type ShellRule struct {
+	Name  string
+	Tools []string // Only arguments after one of these commands count; empty for any command
+
+	Flags        []string // Flags taking a secret: "--password" matches --password=V and --password V, "-p" matches -pV and -p V
+	AttachedOnly bool     // Short flags only take attached values (-pV), as with mysql
+
+	Env      *regexp.Regexp // Variables holding a secret, in NAME=V assignments
+	EnvFlags []string       // Flags whose value is NAME=V, e.g. docker -e; Env then applies to them only
+
+	Args []int // Secret positional arguments, counted from 1 after the command
+}
+
+// secretEnvNames matches variable names that usually hold secrets.
+var secretEnvNames = regexp.MustCompile(`(?i)api_?key|secret|pass(?:word|wd)?|token|cred(?:ential)?s?`)
+
+// DefaultShellRules returns the built-in rules for secrets passed on the
+// command line.
+func DefaultShellRules() []ShellRule {
+	return []ShellRule{
+		{Name: "password-flag", Flags: []string{"--password", "--passwd"}},
+		{Name: "token-flag", Flags: []string{"--token"}},
+		{Name: "api-key-flag", Flags: []string{"--api-key"}},
+		{Name: "secret-flag", Flags: []string{"--secret"}},
+
+		// mysql -p takes no separate value: "-p mydb" prompts for a password
+		{
+			Name:         "mysql-password",
+			Tools:        []string{"mysql", "mysqldump", "mysqladmin", "mysqlimport", "mysqlcheck", "mariadb", "mariadb-dump"},
+			Flags:        []string{"-p"},
+			AttachedOnly: true,
+		},
+		{Name: "sshpass-password", Tools: []string{"sshpass"}, Flags: []string{"-p"}},
+		{Name: "htpasswd-password", Tools: []string{"htpasswd"}, Args: []int{3}},
+
+		// export TOKEN=..., DB_PASSWORD=... psql
+		{Name: "secret-env", Env: secretEnvNames},
+		{
+			Name:     "docker-secret-env",
+			Tools:    []string{"docker", "podman", "nerdctl"},
+			Env:      secretEnvNames,
+			EnvFlags: []string{"-e", "--env", "--build-arg"},
+		},
+		{
+			Name:     "kubectl-secret",
+			Tools:    []string{"kubectl", "oc"},
+			Env:      regexp.MustCompile(`(?i)password|secret|token|key`),
+			EnvFlags: []string{"--from-literal"},
+		},
+	}
+}
+
+// wordSecret is a secret found in a word: the value from offset from on.
+// name is what its variable is called after: the flag or variable it was
+// given to, or else the rule.
+type wordSecret struct {
+	word shellWord
+	from int
+	name string
+}
+
+// applyRule redacts what a shell rule finds in command, re-quoting each
+// redacted word so it stays one word. Allowlisted values and references to
+// variables are left alone.
+func (s *Sanitizer) applyRule(r ShellRule, command string) (string, bool) {
+	var found []wordSecret
+	for _, cmd := range parseShell(command).commands() {
+		found = append(found, r.secrets(cmd)...)
+	}
+
+	// Replace from the end so earlier offsets stay valid
+	slices.SortFunc(found, func(a, b wordSecret) int { return b.word.start - a.word.start })
+
+	redacted := false
+	for _, secret := range found {
+		value := secret.word.value[secret.from:]
+		if value == "" || needsNoRedaction(value) || s.allowlisted(value) {
+			continue
+		}
+		name := s.variables.name(value, secret.name, r.Name)
+		command = redactWord(command, secret.word, secret.from, marker(name))
+		redacted = true
+	}
+	return command, redacted
+}
+
+// applyRuleToText applies a shell rule to text that is not a command, such
+// as captured output, one line at a time so that an unbalanced quote in one
+// line does not hide the lines after it.
+func (s *Sanitizer) applyRuleToText(r ShellRule, text string) (string, bool) {
+	lines := strings.Split(text, "\n")
+	redacted := false
+	for i, line := range lines {
+		if sanitized, ok := s.applyRule(r, line); ok {
+			lines[i] = sanitized
+			redacted = true
+		}
+	}
+	return strings.Join(lines, "\n"), redacted
+}
+
+// secrets returns the secrets the rule finds in a simple command.
+func (r ShellRule) secrets(cmd shellCommand) []wordSecret {
+	var found []wordSecret
+	args := cmd.args
+
+	tool := 0
+	if len(r.Tools) > 0 {
+		tool = slices.IndexFunc(args, func(w shellWord) bool {
+			return slices.Contains(r.Tools, path.Base(w.value))
+		})
+		if tool < 0 {
+			return nil
+		}
+	}
+
+	if len(r.EnvFlags) == 0 {
+		for _, w := range cmd.assignments {
+			if secret, ok := r.assignment(w, 0); ok {
+				found = append(found, secret)
+			}
+		}
+	}
+
+	position := 0
+	options := true
+	for i := tool + 1; i < len(args); i++ {
+		w := args[i]
+		if options && w.value == "--" {
+			options = false
+			continue
+		}
+		if !options || !strings.HasPrefix(w.value, "-") || w.value == "-" {
+			position++
+			if slices.Contains(r.Args, position) {
+				found = append(found, wordSecret{word: w, name: r.Name})
+			}
+			continue
+		}
+
+		if flag, from, ok := flagValue(r.Flags, r.AttachedOnly, w.value); ok {
+			// -p says nothing about what it takes, the rule name does
+			name := flag
+			if !strings.HasPrefix(flag, "--") {
+				name = r.Name
+			}
+			if from < 0 && i+1 < len(args) {
+				i++
+				found = append(found, wordSecret{word: args[i], name: name})
+			} else if from >= 0 {
+				found = append(found, wordSecret{word: w, from: from, name: name})
+			}
+			continue
+		}
+
+		if _, from, ok := flagValue(r.EnvFlags, false, w.value); ok {
+			if from < 0 && i+1 < len(args) {
+				i++
+				w, from = args[i], 0
+			}
+			if from >= 0 {
+				if secret, ok := r.assignment(w, from); ok {
+					found = append(found, secret)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// assignment checks a NAME=V value starting at offset from of a word.
+func (r ShellRule) assignment(w shellWord, from int) (wordSecret, bool) {
+	name, _, ok := strings.Cut(w.value[from:], "=")
+	if !ok || r.Env == nil || !r.Env.MatchString(name) {
+		return wordSecret{}, false
+	}
+	return wordSecret{word: w, from: from + len(name) + 1, name: strings.TrimSuffix(name, "+")}, true
+}
+
+// flagValue reports which of flags arg is, and where its value starts in
+// arg, or -1 if the value is the next argument.
+func flagValue(flags []string, attachedOnly bool, arg string) (string, int, bool) {
+	for _, flag := range flags {
+		short := len(flag) == 2 && flag[1] != '-'
+		switch {
+		case arg == flag && !(short && attachedOnly):
+			return flag, -1, true
+		case short && len(arg) > 2 && strings.HasPrefix(arg, flag):
+			return flag, 2, true
+		case !short && strings.HasPrefix(arg, flag+"="):
+			return flag, len(flag) + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// redactWord replaces a word's value from offset from on with a marker. The
+// quote the value was in is closed again, so --password="a b"c becomes
+// --password="<REDACTED:PASSWORD>".
+func redactWord(command string, w shellWord, from int, placeholder string) string {
+	raw := command[w.start:w.offsets[from]] + placeholder
+	switch w.quotes[from] {
+	case '"':
+		raw += `"`
+	case '\'', '$':
+		raw += "'"
+	}
+	return command[:w.start] + raw + command[w.end:]
+}
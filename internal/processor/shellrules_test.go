@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestSanitizer_ShellRules(t *testing.T) {
+	sanitizer := NewSanitizer().WithEntropy(EntropyConfig{})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "quoted and unquoted parts of one value",
+			input:    `mysql --password="a b"c db`,
+			expected: `mysql --password="${PASSWORD}" db`,
+		},
+		{
+			name:     "ANSI-C quoted value",
+			input:    `deploy --token $'s3cr\x21t' prod`,
+			expected: `deploy --token "${TOKEN}" prod`,
+		},
+		{
+			name:     "value starting unquoted",
+			input:    `app --password=pre'a b'post -v`,
+			expected: `app --password="${PASSWORD}" -v`,
+		},
+		{
+			name:     "whole flag quoted",
+			input:    `app "--password=hunter2"`,
+			expected: `app "--password=${PASSWORD}"`,
+		},
+		{
+			name:     "escaped space",
+			input:    `app --password=a\ b -v`,
+			expected: `app --password="${PASSWORD}" -v`,
+		},
+		{
+			name:     "assignment before a command",
+			input:    `PGPASSWORD='p w' psql -h db`,
+			expected: `PGPASSWORD="${PGPASSWORD}" psql -h db`,
+		},
+		{
+			name:     "assignment later in a list",
+			input:    `cd app && API_TOKEN=abc ./deploy`,
+			expected: `cd app && API_TOKEN="${API_TOKEN}" ./deploy`,
+		},
+		{
+			name:     "export with ANSI-C quoting",
+			input:    `export API_TOKEN=$'abc\ndef'`,
+			expected: `export API_TOKEN="${API_TOKEN}"`,
+		},
+		{
+			name:     "argument that only looks like an assignment",
+			input:    `echo TOKEN=abc`,
+			expected: `echo TOKEN=abc`,
+		},
+		{
+			name:     "mysql attached password in double quotes",
+			input:    `mysql -uroot -p"p w" db`,
+			expected: `mysql -uroot -p"${MYSQL_PASSWORD}" db`,
+		},
+		{
+			name:     "mysql password prompt",
+			input:    `mysql -u root -p db`,
+			expected: `mysql -u root -p db`,
+		},
+		{
+			name:     "mysql inside docker exec",
+			input:    `docker exec -it db mysql -psecret`,
+			expected: `docker exec -it db mysql -p"${MYSQL_PASSWORD}"`,
+		},
+		{
+			name:     "-p of another tool",
+			input:    `psql -h db -p5432 app`,
+			expected: `psql -h db -p5432 app`,
+		},
+		{
+			name:     "docker env flag with a separate value",
+			input:    `docker run --env "API_KEY=a b" img`,
+			expected: `docker run --env "API_KEY=${API_KEY}" img`,
+		},
+		{
+			name:     "kubectl literal",
+			input:    `kubectl create secret generic db --from-literal=password='p w'`,
+			expected: `kubectl create secret generic db --from-literal=password="${PASSWORD}"`,
+		},
+		{
+			name:     "trailing docker -e",
+			input:    `docker run -e`,
+			expected: `docker run -e`,
+		},
+		{
+			name:     "trailing docker --env",
+			input:    `docker run --env`,
+			expected: `docker run --env`,
+		},
+		{
+			name:     "trailing docker --build-arg",
+			input:    `docker build --build-arg`,
+			expected: `docker build --build-arg`,
+		},
+		{
+			name:     "trailing kubectl --from-literal",
+			input:    `kubectl create secret generic db --from-literal`,
+			expected: `kubectl create secret generic db --from-literal`,
+		},
+		{
+			name:     "positional argument",
+			input:    `htpasswd -b .htpasswd admin hunter2`,
+			expected: `htpasswd -b .htpasswd admin "${HTPASSWD_PASSWORD}"`,
+		},
+		{
+			name:     "sshpass separate value",
+			input:    `sshpass -p 'x y' ssh host`,
+			expected: `sshpass -p "${SSHPASS_PASSWORD}" ssh host`,
+		},
+		{
+			name:     "here-document body is not an argument",
+			input:    "cat <<EOF\n--password=x\nEOF",
+			expected: "cat <<EOF\n--password=x\nEOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sanitizer.SanitizeString(tt.input)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+
+			// Redacting must not change how the command splits into words
+			if got, want := len(parseShell(result).words), len(parseShell(tt.input).words); got != want {
+				t.Errorf("expected %d words after redaction, got %d", want, got)
+			}
+		})
+	}
+}
+
+func TestSanitizer_CustomShellRules(t *testing.T) {
+	sanitizer := NewSanitizer().
+		WithEntropy(EntropyConfig{}).
+		WithShellRules([]ShellRule{{Name: "acme-pin", Tools: []string{"acme"}, Flags: []string{"--pin"}}}).
+		WithAllowlist([]string{"changeme"})
+
+	entries := []history.Entry{
+		{Number: 1, Command: "acme unlock --pin 1234"},
+		{Number: 2, Command: "app --password changeme"},
+	}
+	result, redactions := sanitizer.Process(entries)
+
+	if result[0].Command != `acme unlock --pin "${PIN}"` {
+		t.Errorf("expected the pin redacted, got %q", result[0].Command)
+	}
+	if result[1].Command != entries[1].Command {
+		t.Errorf("expected the allowlisted password kept, got %q", result[1].Command)
+	}
+	if len(redactions) != 1 || redactions[0].PatternName != "acme-pin" {
+		t.Errorf("expected one acme-pin redaction, got %+v", redactions)
+	}
+}
@@ -0,0 +1,481 @@
+package processor
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// shellWord is a word of a command line, split the way a POSIX shell or zsh
+// splits it. Control operators and redirections are words too, with op set.
+type shellWord struct {
+	start, end int    // span in the line, quotes included
+	value      string // the word after quote removal
+	offsets    []int  // offsets[i] is where value[i] came from in the line
+	quotes     []byte // quotes[i] is how value[i] was quoted: 0, '\\', '\'', '"' or '$' for $'...'
+	op         string
+}
+
+// controlOps end a simple command.
+var controlOps = map[string]bool{
+	";": true, ";;": true, "&": true, "&&": true, "|": true, "||": true, "|&": true,
+	"(": true, ")": true, "\n": true,
+}
+
+// shellOps are the operators recognised between words, longest first.
+var shellOps = []string{
+	"&>>", "<<<", "<<-",
+	"&&", "||", ";;", "|&", ">>", "<<", "<&", ">&", "&>", "<>", ">|",
+	";", "&", "|", "(", ")", "<", ">",
+}
+
+// shellLine is a parsed command line.
+type shellLine struct {
+	words []shellWord
+
+	// quoted holds the spans of the line that are not shell syntax: quoted
+	// strings, comments and here-document bodies
+	quoted []quotedSpan
+}
+
+// quotedSpan is a quoted part of a line, quotes included. quote is the
+// quote character, '$' for $'...', '#' for a comment or '<' for a
+// here-document body.
+type quotedSpan struct {
+	start, end int
+	quote      byte
+}
+
+type shellParser struct {
+	line      string
+	pos       int
+	result    shellLine
+	heredocs  []heredoc // waiting for the end of the line
+	delimNext bool      // the next word is a here-document delimiter
+	stripTabs bool
+}
+
+type heredoc struct {
+	delim     string
+	stripTabs bool
+}
+
+// parseShell splits a command line into words. It understands single, double
+// and $'...' quoting, backslash escapes and line continuations, $(...),
+// ${...} and backquote substitutions, comments and here-documents. It never
+// fails: unterminated quotes run to the end of the line.
+func parseShell(line string) shellLine {
+	p := &shellParser{line: line}
+	for p.pos < len(line) {
+		c := line[p.pos]
+		switch {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '\\' && strings.HasPrefix(line[p.pos:], "\\\n"):
+			p.pos += 2
+		case c == '\n':
+			p.result.words = append(p.result.words, shellWord{start: p.pos, end: p.pos + 1, op: "\n"})
+			p.pos++
+			p.skipHeredocs()
+		case c == '#':
+			end := strings.IndexByte(line[p.pos:], '\n')
+			if end < 0 {
+				end = len(line) - p.pos
+			}
+			p.result.quoted = append(p.result.quoted, quotedSpan{p.pos, p.pos + end, '#'})
+			p.pos += end
+		case (c == '<' || c == '>') && strings.HasPrefix(line[p.pos+1:], "("):
+			p.word() // process substitution
+		case strings.IndexByte(";&|()<>", c) >= 0:
+			p.operator()
+		default:
+			p.word()
+		}
+	}
+	return p.result
+}
+
+func (p *shellParser) operator() {
+	for _, op := range shellOps {
+		if strings.HasPrefix(p.line[p.pos:], op) {
+			p.result.words = append(p.result.words, shellWord{start: p.pos, end: p.pos + len(op), op: op})
+			p.pos += len(op)
+			if op == "<<" || op == "<<-" {
+				p.delimNext, p.stripTabs = true, op == "<<-"
+			}
+			return
+		}
+	}
+}
+
+// skipHeredocs passes over the bodies of here-documents started on the line
+// that just ended.
+func (p *shellParser) skipHeredocs() {
+	for _, doc := range p.heredocs {
+		start := p.pos
+		for p.pos < len(p.line) {
+			end := strings.IndexByte(p.line[p.pos:], '\n')
+			next := p.pos + end + 1
+			if end < 0 {
+				end, next = len(p.line)-p.pos, len(p.line)
+			}
+			text := p.line[p.pos : p.pos+end]
+			if doc.stripTabs {
+				text = strings.TrimLeft(text, "\t")
+			}
+			if text == doc.delim {
+				p.result.quoted = append(p.result.quoted, quotedSpan{start, p.pos, '<'})
+				p.pos = next
+				start = -1
+				break
+			}
+			p.pos = next
+		}
+		if start >= 0 {
+			p.result.quoted = append(p.result.quoted, quotedSpan{start, p.pos, '<'})
+		}
+	}
+	p.heredocs = nil
+}
+
+func (p *shellParser) word() {
+	w := shellWord{start: p.pos}
+	var value []byte
+	add := func(s string, offset int, quote byte) {
+		for i := 0; i < len(s); i++ {
+			value = append(value, s[i])
+			w.offsets = append(w.offsets, offset)
+			w.quotes = append(w.quotes, quote)
+		}
+	}
+	line := p.line
+
+	for p.pos < len(line) {
+		c := line[p.pos]
+		next := byte(0)
+		if p.pos+1 < len(line) {
+			next = line[p.pos+1]
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			p.finish(w, value)
+			return
+		case (c == '<' || c == '>') && next == '(':
+			start := p.pos
+			p.pos = p.balanced(p.pos+1, '(', ')')
+			add(line[start:p.pos], start, 0)
+		case strings.IndexByte(";&|()<>", c) >= 0:
+			p.finish(w, value)
+			return
+		case c == '\\':
+			switch {
+			case next == '\n':
+				p.pos += 2
+			case p.pos+1 < len(line):
+				add(line[p.pos+1:p.pos+2], p.pos, '\\')
+				p.pos += 2
+			default:
+				add("\\", p.pos, 0)
+				p.pos++
+			}
+		case c == '\'':
+			end := strings.IndexByte(line[p.pos+1:], '\'')
+			close := p.pos + 1 + end
+			if end < 0 {
+				close = len(line)
+			}
+			for i := p.pos + 1; i < close; i++ {
+				add(line[i:i+1], i, '\'')
+			}
+			p.quote(p.pos, min(close+1, len(line)), '\'')
+		case c == '$' && next == '\'':
+			p.ansiC(add)
+		case c == '"' || (c == '$' && next == '"'):
+			p.double(add)
+		case c == '$' && (next == '(' || next == '{'):
+			start := p.pos
+			p.pos = p.balanced(p.pos+1, next, closing(next))
+			add(line[start:p.pos], start, 0)
+		case c == '`':
+			start := p.pos
+			p.pos = p.backquote(p.pos)
+			add(line[start:p.pos], start, 0)
+		default:
+			add(line[p.pos:p.pos+1], p.pos, 0)
+			p.pos++
+		}
+	}
+	p.finish(w, value)
+}
+
+// finish records a completed word.
+func (p *shellParser) finish(w shellWord, value []byte) {
+	w.end = p.pos
+	w.value = string(value)
+	w.offsets = append(w.offsets, p.pos)
+	if w.end == w.start {
+		return
+	}
+	p.result.words = append(p.result.words, w)
+
+	if p.delimNext {
+		p.heredocs = append(p.heredocs, heredoc{delim: w.value, stripTabs: p.stripTabs})
+		p.delimNext = false
+	}
+}
+
+// quote records a quoted span and moves past it.
+func (p *shellParser) quote(start, end int, quote byte) {
+	p.result.quoted = append(p.result.quoted, quotedSpan{start, end, quote})
+	p.pos = end
+}
+
+// double reads a "..." or $"..." string.
+func (p *shellParser) double(add func(string, int, byte)) {
+	line := p.line
+	start := p.pos
+	i := p.pos + 1
+	if line[p.pos] == '$' {
+		i++
+	}
+
+	for i < len(line) && line[i] != '"' {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && strings.IndexByte("$`\"\\\n", line[i+1]) >= 0:
+			if line[i+1] != '\n' {
+				add(line[i+1:i+2], i, '"')
+			}
+			i += 2
+		case line[i] == '$' && i+1 < len(line) && (line[i+1] == '(' || line[i+1] == '{'):
+			end := p.balanced(i+1, line[i+1], closing(line[i+1]))
+			add(line[i:end], i, '"')
+			i = end
+		case line[i] == '`':
+			end := p.backquote(i)
+			add(line[i:end], i, '"')
+			i = end
+		default:
+			add(line[i:i+1], i, '"')
+			i++
+		}
+	}
+	p.quote(start, min(i+1, len(line)), '"')
+}
+
+// ansiC reads a $'...' string, decoding its escapes.
+func (p *shellParser) ansiC(add func(string, int, byte)) {
+	line := p.line
+	start := p.pos
+	i := p.pos + 2
+
+	for i < len(line) && line[i] != '\'' {
+		if line[i] != '\\' || i+1 >= len(line) {
+			add(line[i:i+1], i, '$')
+			i++
+			continue
+		}
+
+		decoded, n := decodeEscape(line[i+1:])
+		add(decoded, i, '$')
+		i += 1 + n
+	}
+	p.quote(start, min(i+1, len(line)), '$')
+}
+
+// decodeEscape decodes the escape after a backslash in $'...', returning the
+// text it stands for and how many bytes it took.
+func decodeEscape(s string) (string, int) {
+	simple := map[byte]string{
+		'n': "\n", 't': "\t", 'r': "\r", 'a': "\a", 'b': "\b", 'f': "\f", 'v': "\v",
+		'e': "\x1b", 'E': "\x1b", '\\': "\\", '\'': "'", '"': "\"", '?': "?",
+	}
+	if decoded, ok := simple[s[0]]; ok {
+		return decoded, 1
+	}
+
+	digits := func(from, max int, isDigit func(byte) bool) string {
+		end := from
+		for end < len(s) && end-from < max && isDigit(s[end]) {
+			end++
+		}
+		return s[from:end]
+	}
+	isHexDigit := func(b byte) bool { return strings.IndexByte("0123456789abcdefABCDEF", b) >= 0 }
+
+	switch s[0] {
+	case 'x':
+		if hex := digits(1, 2, isHexDigit); hex != "" {
+			n, _ := strconv.ParseUint(hex, 16, 8)
+			return string([]byte{byte(n)}), 1 + len(hex)
+		}
+	case 'u', 'U':
+		max := 4
+		if s[0] == 'U' {
+			max = 8
+		}
+		if hex := digits(1, max, isHexDigit); hex != "" {
+			n, _ := strconv.ParseUint(hex, 16, 32)
+			if utf8.ValidRune(rune(n)) {
+				return string(rune(n)), 1 + len(hex)
+			}
+		}
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		octal := digits(0, 3, func(b byte) bool { return b >= '0' && b <= '7' })
+		n, _ := strconv.ParseUint(octal, 8, 16)
+		return string([]byte{byte(n)}), len(octal)
+	}
+	return "\\" + s[:1], 1
+}
+
+// balanced returns the offset just past the bracket closing the one at
+// open, skipping over quoted text.
+func (p *shellParser) balanced(open int, openCh, closeCh byte) int {
+	line := p.line
+	depth := 0
+	for i := open; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '\'':
+			if end := strings.IndexByte(line[i+1:], '\''); end >= 0 {
+				i += end + 1
+			} else {
+				return len(line)
+			}
+		case '"':
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+		case openCh:
+			depth++
+		case closeCh:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(line)
+}
+
+// closing returns the bracket closing ( or {.
+func closing(open byte) byte {
+	if open == '(' {
+		return ')'
+	}
+	return '}'
+}
+
+// backquote returns the offset just past the backquote closing the one at
+// open.
+func (p *shellParser) backquote(open int) int {
+	line := p.line
+	for i := open + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1
+		}
+	}
+	return len(line)
+}
+
+// quoteAt returns the quoted span of the line offset lies in, if any.
+func (l shellLine) quoteAt(offset int) (quotedSpan, bool) {
+	for _, span := range l.quoted {
+		if offset >= span.start && offset < span.end {
+			return span, true
+		}
+	}
+	return quotedSpan{}, false
+}
+
+// shellCommand is a simple command: its variable assignments and its
+// arguments, the command name first. Redirections are left out.
+type shellCommand struct {
+	assignments []shellWord
+	args        []shellWord
+}
+
+// shellKeywords may come before a command name without being one.
+var shellKeywords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true, "elif": true,
+	"fi": true, "do": true, "done": true, "while": true, "until": true, "time": true,
+}
+
+// declarationCommands take assignments as arguments.
+var declarationCommands = map[string]bool{
+	"export": true, "declare": true, "typeset": true, "local": true, "readonly": true, "env": true,
+}
+
+// commands splits the words of a line into simple commands.
+func (l shellLine) commands() []shellCommand {
+	var commands []shellCommand
+	var current shellCommand
+
+	for i := 0; i < len(l.words); i++ {
+		w := l.words[i]
+		switch {
+		case controlOps[w.op]:
+			if len(current.assignments) > 0 || len(current.args) > 0 {
+				commands = append(commands, current)
+			}
+			current = shellCommand{}
+		case w.op != "":
+			// A redirection: drop its target, and a file descriptor number
+			// written straight before it
+			if n := len(current.args); n > 0 && current.args[n-1].end == w.start && isDigits(current.args[n-1].value) {
+				current.args = current.args[:n-1]
+			}
+			i++
+		case len(current.args) == 0 && isAssignment(w):
+			current.assignments = append(current.assignments, w)
+		case len(current.args) == 0 && shellKeywords[w.value]:
+		case len(current.args) > 0 && declarationCommands[current.args[0].value] && isAssignment(w):
+			current.assignments = append(current.assignments, w)
+		default:
+			current.args = append(current.args, w)
+		}
+	}
+
+	if len(current.assignments) > 0 || len(current.args) > 0 {
+		commands = append(commands, current)
+	}
+	return commands
+}
+
+// isAssignment reports whether a word assigns a variable: NAME=value, with
+// an unquoted name.
+func isAssignment(w shellWord) bool {
+	name, _, ok := strings.Cut(w.value, "=")
+	if !ok || !isVariableName(strings.TrimSuffix(name, "+")) {
+		return false
+	}
+	for i := 0; i <= len(name); i++ {
+		if w.quotes[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isVariableName reports whether s is a valid shell variable name.
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+// splitWords returns the words of line, with operators shown as [op].
+func splitWords(line string) []string {
+	var words []string
+	for _, w := range parseShell(line).words {
+		if w.op != "" {
+			words = append(words, "["+strings.ReplaceAll(w.op, "\n", `\n`)+"]")
+			continue
+		}
+		words = append(words, w.value)
+	}
+	return words
+}
+
+func TestParseShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "plain words",
+			input:    "git  commit\t-m msg",
+			expected: []string{"git", "commit", "-m", "msg"},
+		},
+		{
+			name:     "quotes join into one word",
+			input:    `mysql --password="a b"c db`,
+			expected: []string{"mysql", "--password=a bc", "db"},
+		},
+		{
+			name:     "single quotes keep backslashes",
+			input:    `echo 'a\nb' "it's"`,
+			expected: []string{"echo", `a\nb`, "it's"},
+		},
+		{
+			name:     "backslash escapes",
+			input:    `echo a\ b "say \"hi\" \$HOME"`,
+			expected: []string{"echo", "a b", `say "hi" $HOME`},
+		},
+		{
+			name:     "ANSI-C quoting",
+			input:    `echo $'tab\there\x21 \'q\' é'`,
+			expected: []string{"echo", "tab\there! 'q' é"},
+		},
+		{
+			name:     "locale quoting",
+			input:    `echo $"hello world"`,
+			expected: []string{"echo", "hello world"},
+		},
+		{
+			name:     "substitutions stay whole",
+			input:    `echo $(date +"%Y %m") ${HOME:-/} ` + "`id -u`",
+			expected: []string{"echo", `$(date +"%Y %m")`, "${HOME:-/}", "`id -u`"},
+		},
+		{
+			name:     "operators",
+			input:    "make&&make install||echo fail; ls|wc -l &",
+			expected: []string{"make", "[&&]", "make", "install", "[||]", "echo", "fail", "[;]", "ls", "[|]", "wc", "-l", "[&]"},
+		},
+		{
+			name:     "redirections",
+			input:    "cmd 2>/dev/null >>log <in",
+			expected: []string{"cmd", "2", "[>]", "/dev/null", "[>>]", "log", "[<]", "in"},
+		},
+		{
+			name:     "comment",
+			input:    "ls # list 'files",
+			expected: []string{"ls"},
+		},
+		{
+			name:     "hash inside a word",
+			input:    "echo a#b",
+			expected: []string{"echo", "a#b"},
+		},
+		{
+			name:     "line continuation",
+			input:    "docker run \\\n  --rm image",
+			expected: []string{"docker", "run", "--rm", "image"},
+		},
+		{
+			name:     "here-document body is skipped",
+			input:    "cat <<-EOF > out\n\tpassword: hunter2\n\tEOF\necho done",
+			expected: []string{"cat", "[<<-]", "EOF", "[>]", "out", `[\n]`, "echo", "done"},
+		},
+		{
+			name:     "unterminated quote runs to the end",
+			input:    `echo "abc def`,
+			expected: []string{"echo", "abc def"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitWords(tt.input)
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestShellLine_Commands(t *testing.T) {
+	line := parseShell("FOO=1 BAR='x y' sudo -E app --flag 2>&1 | export TOKEN=abc; env A=1 run")
+	commands := line.commands()
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(commands))
+	}
+
+	values := func(words []shellWord) string {
+		var v []string
+		for _, w := range words {
+			v = append(v, w.value)
+		}
+		return strings.Join(v, " ")
+	}
+
+	tests := []struct {
+		assignments string
+		args        string
+	}{
+		{"FOO=1 BAR=x y", "sudo -E app --flag"},
+		{"TOKEN=abc", "export"},
+		{"A=1", "env run"},
+	}
+	for i, tt := range tests {
+		if got := values(commands[i].assignments); got != tt.assignments {
+			t.Errorf("command %d: expected assignments %q, got %q", i, tt.assignments, got)
+		}
+		if got := values(commands[i].args); got != tt.args {
+			t.Errorf("command %d: expected args %q, got %q", i, tt.args, got)
+		}
+	}
+}
+
+func TestShellLine_Quoted(t *testing.T) {
+	line := parseShell(`echo 'a' "b" $'c' d # e` + "\ncat <<X\nf\nX")
+	tests := []struct {
+		offset int
+		quoted bool
+	}{
+		{0, false},  // echo
+		{6, true},   // a
+		{10, true},  // b
+		{15, true},  // c
+		{18, false}, // d
+		{22, true},  // e
+		{32, true},  // f
+	}
+	for _, tt := range tests {
+		if _, got := line.quoteAt(tt.offset); got != tt.quoted {
+			t.Errorf("offset %d: expected quoted %v, got %v", tt.offset, tt.quoted, got)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Variable is a shell variable standing in for a redacted secret. The reader
+// of a runbook sets it before running the steps.
+type Variable struct {
+	Name    string // e.g. DB_PASSWORD, used in commands as ${DB_PASSWORD}
+	Pattern string // pattern or rule that found the secret
+}
+
+// Reference returns how commands refer to the variable.
+func (v Variable) Reference() string {
+	return "${" + v.Name + "}"
+}
+
+// variables hands out one variable per distinct secret, so a password used
+// by several commands is the same ${NAME} in all of them.
+type variables struct {
+	bySecret map[string]string
+	taken    map[string]bool
+	list     []Variable
+}
+
+func newVariables() *variables {
+	return &variables{bySecret: make(map[string]string), taken: make(map[string]bool)}
+}
+
+// name returns the variable for secret, creating it from base if the secret
+// is new. A base already used for another secret gets a numeric suffix.
+func (v *variables) name(secret, base, pattern string) string {
+	if name, ok := v.bySecret[secret]; ok {
+		return name
+	}
+
+	base = variableName(base)
+	name := base
+	for i := 2; v.taken[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	v.bySecret[secret] = name
+	v.taken[name] = true
+	v.list = append(v.list, Variable{Name: name, Pattern: pattern})
+	return name
+}
+
+// minKnownSecret is the shortest secret replaced wherever it appears in text;
+// a shorter one, like a one-letter password, would mangle ordinary words.
+const minKnownSecret = 4
+
+// replaceKnown replaces every secret that already has a variable with its
+// marker, longest first so a secret containing another is replaced whole. It
+// returns the patterns that found the secrets it replaced.
+func (v *variables) replaceKnown(text string) (string, []string) {
+	var secrets []string
+	for secret := range v.bySecret {
+		if len(secret) >= minKnownSecret && strings.Contains(text, secret) {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 {
+		return text, nil
+	}
+	slices.SortFunc(secrets, func(a, b string) int { return cmp.Or(len(b)-len(a), strings.Compare(a, b)) })
+
+	var pairs, patterns []string
+	for _, secret := range secrets {
+		name := v.bySecret[secret]
+		pairs = append(pairs, secret, marker(name))
+		for _, variable := range v.list {
+			if variable.Name == name {
+				patterns = append(patterns, variable.Pattern)
+			}
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(text), patterns
+}
+
+// variableName turns a flag, key or pattern name into a shell variable name:
+// "--api-key" and "api-key" both become API_KEY.
+func variableName(s string) string {
+	s = strings.ToUpper(strings.TrimLeft(s, "-"))
+	s = strings.Trim(nonIdentifier.ReplaceAllString(s, "_"), "_")
+	if s == "" {
+		return "SECRET"
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return "SECRET_" + s
+	}
+	return s
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// Until a command is fully sanitized a secret is replaced by a marker naming
+// its variable; patterns and rules leave markers alone. Pattern replacements
+// still say <REDACTED> (or e.g. <REDACTED_JWT>), and the marker takes its
+// place.
+var (
+	markerRegex      = regexp.MustCompile(`<REDACTED:([A-Z0-9_]+)>`)
+	placeholderRegex = regexp.MustCompile(`<REDACTED(?:_[A-Z]+)*>`)
+	referenceRegex   = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*|\{[A-Za-z_][A-Za-z0-9_]*\})$`)
+)
+
+func marker(name string) string {
+	return "<REDACTED:" + name + ">"
+}
+
+// needsNoRedaction reports whether a secret is really a variable reference,
+// possibly quoted, or holds a marker already.
+func needsNoRedaction(s string) bool {
+	if markerRegex.MatchString(s) {
+		return true
+	}
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return referenceRegex.MatchString(s)
+}
+
+// nameSecret replaces the placeholder in a pattern's replacement text with a
+// marker for secret's variable. Replacements without a placeholder are kept
+// as they are.
+func (s *Sanitizer) nameSecret(replacement, secret, patternName string) string {
+	loc := placeholderRegex.FindStringIndex(replacement)
+	if loc == nil {
+		return replacement
+	}
+	name := s.variables.name(secret, patternName, patternName)
+	return replacement[:loc[0]] + marker(name) + replacement[loc[1]:]
+}
+
+// renderPlaceholders turns the markers in a sanitized command into
+// references to their variables, quoted so that the shell expands them and
+// keeps each one a single word: "${NAME}" outside quotes, ${NAME} inside
+// double quotes, and the single quotes closed around it otherwise.
+func renderPlaceholders(command string) string {
+	matches := markerRegex.FindAllStringSubmatchIndex(command, -1)
+	if matches == nil {
+		return command
+	}
+
+	line := parseShell(command)
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		start, end := m[0], m[1]
+		ref := Variable{Name: command[m[2]:m[3]]}.Reference()
+
+		span, quoted := line.quoteAt(start)
+		switch {
+		case !quoted:
+			ref = `"` + ref + `"`
+		case span.quote == '\'' || span.quote == '$':
+			opener, reopen := "'", "'"
+			if span.quote == '$' {
+				opener, reopen = "$'", "$'"
+			}
+			before, after := "'", reopen
+			if start == span.start+len(opener) {
+				before, start = "", span.start
+			}
+			if end == span.end-1 && command[end] == '\'' {
+				after, end = "", span.end
+			}
+			ref = before + `"` + ref + `"` + after
+		}
+		command = command[:start] + ref + command[end:]
+	}
+	return command
+}
+
+// renderPlain turns markers into variable references in text that is not a
+// command, such as captured output.
+func renderPlain(text string) string {
+	return markerRegex.ReplaceAllString(text, "$${$1}")
+}
+
+// Variables returns the variables secrets were replaced with so far, in the
+// order they were first needed.
+func (s *Sanitizer) Variables() []Variable {
+	return append([]Variable(nil), s.variables.list...)
+}
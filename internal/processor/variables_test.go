@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mrf/runbook-generator/internal/history"
+)
+
+func TestSanitizer_Variables(t *testing.T) {
+	sanitizer := NewSanitizer()
+
+	entries := []history.Entry{
+		{Number: 1, Command: "export DB_PASSWORD=hunter2hunter2"},
+		{Number: 2, Command: "psql --password=hunter2hunter2 -h db"},
+		{Number: 3, Command: "mysql --password 'other secret' app"},
+		{Number: 4, Command: "curl -H 'Authorization: Bearer abc123def456ghi789' https://api"},
+	}
+	result, _ := sanitizer.Process(entries)
+
+	expected := []string{
+		`export DB_PASSWORD="${DB_PASSWORD}"`,
+		`psql --password="${DB_PASSWORD}" -h db`,
+		`mysql --password "${PASSWORD}" app`,
+		`curl -H 'Authorization: Bearer '"${BEARER_TOKEN}" https://api`,
+	}
+	for i, want := range expected {
+		if result[i].Command != want {
+			t.Errorf("entry %d: expected %q, got %q", i+1, want, result[i].Command)
+		}
+	}
+
+	variables := sanitizer.Variables()
+	want := []Variable{
+		{Name: "DB_PASSWORD", Pattern: "secret-env"},
+		{Name: "PASSWORD", Pattern: "password-flag"},
+		{Name: "BEARER_TOKEN", Pattern: "bearer-token"},
+	}
+	if len(variables) != len(want) {
+		t.Fatalf("expected %d variables, got %+v", len(want), variables)
+	}
+	for i := range want {
+		if variables[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], variables[i])
+		}
+	}
+}
+
+func TestSanitizer_VariableNameClash(t *testing.T) {
+	sanitizer := NewSanitizer()
+	result, _ := sanitizer.Process([]history.Entry{
+		{Number: 1, Command: "app --token first-token"},
+		{Number: 2, Command: "app --token second-token"},
+	})
+
+	if result[0].Command != `app --token "${TOKEN}"` || result[1].Command != `app --token "${TOKEN_2}"` {
+		t.Errorf("expected TOKEN and TOKEN_2, got %q and %q", result[0].Command, result[1].Command)
+	}
+}
+
+func TestSanitizer_References(t *testing.T) {
+	sanitizer := NewSanitizer()
+
+	tests := []string{
+		`mysql --password="$DB_PASS" app`,
+		`deploy --token ${DEPLOY_TOKEN}`,
+		`export API_KEY="${API_KEY}"`,
+		`curl -H "Authorization: Bearer ${BEARER_TOKEN}" https://api`,
+		`psql postgres://user:"${CONNECTION_STRING_PASSWORD}"@db/app`,
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if result := sanitizer.SanitizeString(input); result != input {
+				t.Errorf("expected %q, got %q", input, result)
+			}
+		})
+	}
+}
+
+func TestRenderPlaceholders(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `psql postgres://u:<REDACTED:PW>@h`,
+			expected: `psql postgres://u:"${PW}"@h`,
+		},
+		{
+			input:    `curl -H "Bearer <REDACTED:TOKEN>" x`,
+			expected: `curl -H "Bearer ${TOKEN}" x`,
+		},
+		{
+			input:    `echo '<REDACTED:A>'`,
+			expected: `echo "${A}"`,
+		},
+		{
+			input:    `echo 'a <REDACTED:A> b'`,
+			expected: `echo 'a '"${A}"' b'`,
+		},
+		{
+			input:    `echo 'user:<REDACTED:A>' '<REDACTED:B>:host'`,
+			expected: `echo 'user:'"${A}" "${B}"':host'`,
+		},
+		{
+			input:    `echo $'<REDACTED:A>\n'`,
+			expected: `echo "${A}"$'\n'`,
+		},
+		{
+			input:    "ls # <REDACTED:A>",
+			expected: "ls # ${A}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := renderPlaceholders(tt.input); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestVariableName(t *testing.T) {
+	tests := map[string]string{
+		"--api-key":           "API_KEY",
+		"connection-string":   "CONNECTION_STRING",
+		"db.password":         "DB_PASSWORD",
+		"1password-secret":    "SECRET_1PASSWORD_SECRET",
+		"high-entropy-string": "HIGH_ENTROPY_STRING",
+		"--":                  "SECRET",
+	}
+	for input, want := range tests {
+		if got := variableName(input); got != want {
+			t.Errorf("%q: expected %q, got %q", input, want, got)
+		}
+	}
+}